@@ -0,0 +1,52 @@
+package v1alpha1
+
+import (
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+	k8sruntime "k8s.io/apimachinery/pkg/runtime"
+)
+
+// BeatSpec defines the celery beat scheduler role.
+// Beat is a singleton, only one role group is allowed and it always runs exactly one replica,
+// otherwise the periodic tasks would be sent multiple times.
+type BeatSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinProperties=1
+	// +kubebuilder:validation:MaxProperties=1
+	RoleGroups map[string]BeatRoleGroupSpec `json:"roleGroups,omitempty"`
+
+	// The periodic tasks sent by the scheduler, keyed by the schedule name.
+	// If it is set, it replaces the default schedules.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:={"reports.scheduler": {"task": "reports.scheduler", "crontab": "* * * * *"}, "reports.prune_log": {"task": "reports.prune_log", "crontab": "0 0 * * *"}}
+	Schedules map[string]BeatScheduleSpec `json:"schedules,omitempty"`
+
+	Config                         *BeatConfigSpec                 `json:"config,omitempty"`
+	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
+
+// BeatScheduleSpec defines a celery beat periodic task.
+type BeatScheduleSpec struct {
+	// The name of the celery task, e.g. `reports.scheduler` or `cache-warmup`.
+	// +kubebuilder:validation:Required
+	Task string `json:"task"`
+
+	// Crontab expression with five fields: minute, hour, day of month, month and day of week.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^\S+(\s+\S+){4}$`
+	Crontab string `json:"crontab"`
+
+	// Keyword arguments passed to the task, e.g. `{"strategy_name": "top_n_dashboards", "top_n": 5}`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:pruning:PreserveUnknownFields
+	Kwargs *k8sruntime.RawExtension `json:"kwargs,omitempty"`
+}
+
+type BeatConfigSpec struct {
+	*commonsv1alpha1.RoleGroupConfigSpec `json:",inline"`
+}
+
+type BeatRoleGroupSpec struct {
+	Config                         *BeatConfigSpec `json:"config,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
@@ -1,9 +1,41 @@
 package v1alpha1
 
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +kubebuilder:validation:XValidation:rule="!(has(self.authentication) && has(self.authenticationClasses))",message="authentication and authenticationClasses are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!(has(self.remoteUserAuthentication) && (has(self.authentication) || (has(self.authenticationClasses) && size(self.authenticationClasses) > 0)))",message="remoteUserAuthentication and authenticationClasses are mutually exclusive"
+// +kubebuilder:validation:XValidation:rule="!(has(self.session) && self.session.backend == 'redis' && !has(self.cache))",message="the redis session backend requires cache"
+// +kubebuilder:validation:XValidation:rule="!(has(self.alertsAndReports) && has(self.remoteUserAuthentication) && has(self.remoteUserAuthentication.proxy))",message="alertsAndReports can not be combined with the auth proxy of remoteUserAuthentication"
+// +kubebuilder:validation:XValidation:rule="!(has(self.resultsBackend) && has(self.resultsBackend.globalAsyncQueries) && self.resultsBackend.globalAsyncQueries && !has(self.cache))",message="globalAsyncQueries requires cache"
 type ClusterConfigSpec struct {
+	// The authentication classes users log in with. Several OIDC classes are rendered as several OAuth providers,
+	// an LDAP class can not be combined with any other class.
+	// The user registration and role sync settings must be the same in every entry.
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=authenticationClass
+	AuthenticationClasses []AuthenticationSpec `json:"authenticationClasses,omitempty"`
+
+	// Deprecated: set `authenticationClasses` instead, this single authentication class is read as a list of one entry.
+	// It can not be combined with `authenticationClasses`.
 	// +kubebuilder:validation:Optional
 	Authentication *AuthenticationSpec `json:"authentication,omitempty"`
 
+	// Trust the username in a request header set by an authenticating proxy,
+	// e.g. an ingress terminating SSO, or the oauth2-proxy sidecar of the node role.
+	// It can not be combined with `authenticationClasses`.
+	// +kubebuilder:validation:Optional
+	RemoteUserAuthentication *RemoteUserAuthenticationSpec `json:"remoteUserAuthentication,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	Authorization *AuthorizationSpec `json:"authorization,omitempty"`
+
+	// The flask app secret key. If not set, the key is generated and persisted in a secret owned by the cluster.
+	// +kubebuilder:validation:Optional
+	AppSecretKey *AppSecretKeySpec `json:"appSecretKey,omitempty"`
+
 	// Superset administrator user credentials and database connection configurations.
 	// It must contains the key:
 	//   - `adminUser.username`: The first name of the admin user.
@@ -11,32 +43,430 @@ type ClusterConfigSpec struct {
 	//   - `adminUser.lastname`: The last name of the admin user.
 	//   - `adminUser.email`: The email of the admin user.
 	//   - `adminUser.password`: The password of the admin user.
-	//   - `appSecretKey`: It is flask app secret key. You can generate by `openssl rand -hex 32`.
 	//   - `connection.sqlalchemyDatabaseUri`: It is the database connection URI. You can use the following format:
 	//     - `postgresql://<username>:<password>@<host>:<port>/<database>`
+	//     It is not required if `metadataDatabase` is set.
+	//
+	// Deprecated: the key `appSecretKey` is only used to seed the generated secret key, set `clusterConfig.appSecretKey` instead.
 	// +kubebuilder:validation:Required
 	CredentialsSecret string `json:"credentialsSecret"`
 
+	// The metadata database of superset. The operator builds the SQLAlchemy URI from it,
+	// instead of reading `connections.sqlalchemyDatabaseUri` from the credentials secret.
+	// +kubebuilder:validation:Optional
+	MetadataDatabase *MetadataDatabaseSpec `json:"metadataDatabase,omitempty"`
+
+	// The time the pods and the init job wait for the metadata database, and the redis cache if set,
+	// to become reachable before superset is started. The init container fails once it expires and is restarted with backoff.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="5m"
+	DependencyTimeout *metav1.Duration `json:"dependencyTimeout,omitempty"`
+
+	// The connection pool of every superset process to the metadata database.
+	// Every process opens up to `poolSize + maxOverflow` connections: the web server of a node replica,
+	// the beat, and the main and every pool process of a worker replica, 4 by default.
+	// By default a node replica opens up to 20 connections, one per gunicorn thread,
+	// and every other process up to 2, as it runs one task at a time.
+	// So the cluster opens up to roughly
+	// `nodeReplicas * 20 + (beatReplicas + workerReplicas * (concurrency + 1)) * 2` connections by default,
+	// and `(nodeReplicas + beatReplicas + workerReplicas * (concurrency + 1)) * (poolSize + maxOverflow)`
+	// if both are set, which must fit into the connection limit of the database, next to its other clients.
 	// +kubebuilder:validation:Optional
+	DatabaseConnectionPool *DatabaseConnectionPoolSpec `json:"databaseConnectionPool,omitempty"`
+
+	// The redis shared by the node replicas as cache, and by celery as broker and result backend.
+	// Without it every node replica caches on its own, and the metadata database is the celery broker.
+	// +kubebuilder:validation:Optional
+	Cache *CacheSpec `json:"cache,omitempty"`
+
+	// The storage of the sessions and the flags of the session cookie.
+	// +kubebuilder:validation:Optional
+	Session *SessionSpec `json:"session,omitempty"`
+
+	// The storage of the results of the async SQL Lab queries run by the workers,
+	// they are kept across restarts of the pods. Databases can only run queries asynchronously with it.
+	// +kubebuilder:validation:Optional
+	ResultsBackend *ResultsBackendSpec `json:"resultsBackend,omitempty"`
+
+	// Send alerts and scheduled reports of charts and dashboards by email.
+	// They are scheduled by the `reports.scheduler` task of the beat role, and run by the workers.
+	// +kubebuilder:validation:Optional
+	AlertsAndReports *AlertsAndReportsSpec `json:"alertsAndReports,omitempty"`
+
+	// The listener class of the node service, it decides how the web server is exposed:
+	//   - `cluster-internal`: ClusterIP service, only reachable inside the cluster.
+	//   - `external-unstable`: NodePort service.
+	//   - `external-stable`: LoadBalancer service.
+	// It can be overridden by the node role or role group config.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=cluster-internal;external-unstable;external-stable
+	// +kubebuilder:default=external-unstable
 	ListenerClass string `json:"listenerClass,omitempty"`
 
 	// +kubebuilder:validation:Optional
 	VectorAggregatorConfigMapName string `json:"vectorAggregatorConfigMapName,omitempty"`
 }
 
+// AuthorizationSpec defines the authorization spec.
+type AuthorizationSpec struct {
+	// Resolve the roles of a user from OPA at every login, they replace the roles of the user
+	// including the ones of the role mapping. If OPA can not be queried or returns no known role,
+	// the login is rejected and the roles of the user are kept.
+	// +kubebuilder:validation:Optional
+	Opa *OpaSpec `json:"opa,omitempty"`
+}
+
+// OpaSpec defines the OPA authorization spec.
+type OpaSpec struct {
+	// The name of the OPA discovery ConfigMap, it contains the url of the OPA server in the key `OPA`.
+	// +kubebuilder:validation:Required
+	ConfigMapName string `json:"configMapName"`
+
+	// The package of the rules. The rule `user_roles` of the package is queried with the input
+	// `{"username": ..., "email": ...}` and must return the names of the superset roles of the user.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=superset
+	Package string `json:"package,omitempty"`
+}
+
 // AppSecretKeySpec defines the app secret key spec.
+// +kubebuilder:validation:XValidation:rule="!(has(self.existSecret) && has(self.secretKey))",message="existSecret and secretKey are mutually exclusive"
 type AppSecretKeySpec struct {
-	// +kubebuilder:validation=Optional
 	// ExistSecret is the name of the secret that contains the secret key.
 	// It must contain the key `SUPERSET_SECRET_KEY`.
 	// Note: To avoid the key name confusions, the key name must be started with `SUPERSET_`.
+	// After a rotation, it can contain the previous key in `SUPERSET_PREVIOUS_SECRET_KEY`,
+	// remove it once the SecretsReEncrypted condition is True.
+	// +kubebuilder:validation:Optional
 	ExistSecret string `json:"existSecret,omitempty"`
-	// +kubebuilder:validation=Optional
+
 	// If value is not set, the secret will be generated.
 	// When you migrate the Superset instance, you should keep the same secret key in the new instance.
+	// A change is applied once the stored secrets are re-encrypted with the key set before,
+	// i.e. the SecretsReEncrypted condition is True.
+	// +kubebuilder:validation:Optional
 	SecretKey string `json:"secretKey,omitempty"`
 }
 
+// MetadataDatabaseSpec defines the metadata database connection.
+// +kubebuilder:validation:XValidation:rule="!(has(self.tls) && has(self.sslMode) && !(self.sslMode in ['verify-ca', 'verify-full']))",message="tls requires sslMode verify-ca or verify-full"
+type MetadataDatabaseSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Enum=postgresql;mysql
+	Type string `json:"type"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// Defaults to 5432 for postgresql and 3306 for mysql.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	Port int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Database string `json:"database"`
+
+	// The secret containing the keys `username` and `password` of the database user.
+	// They are escaped when the URI is built, so they can contain any character.
+	// +kubebuilder:validation:Required
+	CredentialsSecret string `json:"credentialsSecret"`
+
+	// The SSL mode of the connection, named after the postgresql sslmode.
+	// For mysql it is mapped to the corresponding ssl_mode, `allow` is mapped to `PREFERRED`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=disable;allow;prefer;require;verify-ca;verify-full
+	SSLMode string `json:"sslMode,omitempty"`
+
+	// Verify the server certificate of the database with a CA, the sslMode defaults to `verify-full`.
+	// +kubebuilder:validation:Optional
+	TLS *MetadataDatabaseTLSSpec `json:"tls,omitempty"`
+}
+
+// MetadataDatabaseTLSSpec defines the TLS verification of the metadata database.
+type MetadataDatabaseTLSSpec struct {
+	// The SecretClass providing the CA certificate `ca.crt` the server certificate is verified with.
+	// +kubebuilder:validation:Required
+	CASecretClass string `json:"caSecretClass"`
+}
+
+// DatabaseConnectionPoolSpec defines the SQLAlchemy connection pool of the metadata database.
+// The defaults are derived per role from the threads of a process using a connection at the same time:
+// the 20 gunicorn threads of a node replica, and a single one of the beat and the worker pool processes.
+type DatabaseConnectionPoolSpec struct {
+	// The connections kept open in the pool, defaults to a quarter of the threads, at least 1.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	PoolSize *int32 `json:"poolSize,omitempty"`
+
+	// The connections opened beyond the pool size under load, and closed once returned.
+	// Requests wait for a connection once the pool size and overflow are exhausted.
+	// Defaults to the threads not covered by the pool size, at least 1.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	MaxOverflow *int32 `json:"maxOverflow,omitempty"`
+
+	// The seconds after which a connection is replaced, e.g. before a proxy or firewall drops idle connections.
+	// -1 disables the recycling.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=-1
+	// +kubebuilder:default=3600
+	PoolRecycleSeconds *int32 `json:"poolRecycleSeconds,omitempty"`
+
+	// Test the connections when they are checked out of the pool, so stale connections are replaced transparently.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	PoolPrePing *bool `json:"poolPrePing,omitempty"`
+}
+
+// CacheSpec defines the external redis used as cache and celery broker.
+// +kubebuilder:validation:XValidation:rule="has(self.host) != has(self.sentinel)",message="exactly one of host and sentinel must be set"
+type CacheSpec struct {
+	// The host of a single redis, use `sentinel` for a redis discovered by sentinel instead.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host,omitempty"`
+
+	// The port of the single redis.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=6379
+	Port int32 `json:"port,omitempty"`
+
+	// The secret containing the key `password`, and optionally `username` for redis ACL users.
+	// +kubebuilder:validation:Optional
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default={}
+	DatabaseIndexes *CacheDatabaseIndexesSpec `json:"databaseIndexes,omitempty"`
+
+	// Discover the redis master with sentinel, so a failover of redis is followed.
+	// +kubebuilder:validation:Optional
+	Sentinel *CacheSentinelSpec `json:"sentinel,omitempty"`
+
+	// Connect with TLS, i.e. `rediss://`, to redis and the sentinels.
+	// +kubebuilder:validation:Optional
+	TLS *CacheTLSSpec `json:"tls,omitempty"`
+}
+
+// CacheSentinelSpec defines the sentinels the redis master is discovered with.
+type CacheSentinelSpec struct {
+	// The name of the monitored master.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	MasterName string `json:"masterName"`
+
+	// The sentinels, any of them is asked for the master.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Nodes []CacheSentinelNodeSpec `json:"nodes"`
+
+	// The secret containing the key `password` of the sentinels, if they require authentication.
+	// +kubebuilder:validation:Optional
+	PasswordSecret string `json:"passwordSecret,omitempty"`
+}
+
+// CacheSentinelNodeSpec defines the address of a sentinel.
+type CacheSentinelNodeSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=26379
+	Port int32 `json:"port,omitempty"`
+}
+
+// CacheDatabaseIndexesSpec defines the redis databases of the cache, broker and result backend.
+type CacheDatabaseIndexesSpec struct {
+	// The database of the celery broker.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=0
+	CeleryBroker *int32 `json:"celeryBroker,omitempty"`
+
+	// The database of the celery result backend.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=1
+	CeleryResultBackend *int32 `json:"celeryResultBackend,omitempty"`
+
+	// The database of the caches, they are separated by key prefixes.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=2
+	Cache *int32 `json:"cache,omitempty"`
+
+	// The database of the server side sessions, it must not be evicted.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// +kubebuilder:default=3
+	Session *int32 `json:"session,omitempty"`
+}
+
+// CacheTLSSpec defines the TLS connection to redis.
+type CacheTLSSpec struct {
+	// The SecretClass providing the CA certificate `ca.crt` the server certificates are verified with,
+	// the system CAs are used if not set.
+	// +kubebuilder:validation:Optional
+	CASecretClass string `json:"caSecretClass,omitempty"`
+}
+
+// SessionSpec defines the session storage and cookie.
+// +kubebuilder:validation:XValidation:rule="!(has(self.cookieSameSite) && self.cookieSameSite == 'None' && !(has(self.cookieSecure) && self.cookieSecure))",message="cookieSameSite None requires cookieSecure"
+type SessionSpec struct {
+	// The storage of the sessions:
+	//   - `cookie`: the session is stored in a signed cookie, it can not be revoked.
+	//   - `redis`: only the session id is stored in the cookie, the session is stored in the redis of `cache`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=cookie;redis
+	// +kubebuilder:default=cookie
+	Backend string `json:"backend,omitempty"`
+
+	// Only send the session cookie over https, enable it if superset is served with TLS.
+	// +kubebuilder:validation:Optional
+	CookieSecure *bool `json:"cookieSecure,omitempty"`
+
+	// The SameSite attribute of the session cookie, superset defaults to `Lax`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Strict;Lax;None
+	CookieSameSite string `json:"cookieSameSite,omitempty"`
+
+	// Hide the session cookie from javascript, superset defaults to true.
+	// +kubebuilder:validation:Optional
+	CookieHTTPOnly *bool `json:"cookieHttpOnly,omitempty"`
+}
+
+// ResultsBackendSpec defines the storage of the query results.
+type ResultsBackendSpec struct {
+	// The S3 bucket the results are stored in. The superset image must provide the python `boto3` package.
+	// S3 does not expire the results, configure a lifecycle rule on the bucket to delete them.
+	// +kubebuilder:validation:Required
+	S3 ResultsBackendS3Spec `json:"s3"`
+
+	// Run the queries of charts and dashboards asynchronously in the workers, the web server polls their results.
+	// The events of the queries are sent through the redis of `cache`, which is required.
+	// With sentinel, it requires superset 4.1 or later.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=false
+	GlobalAsyncQueries *bool `json:"globalAsyncQueries,omitempty"`
+}
+
+// ResultsBackendS3Spec defines the S3 bucket of the query results.
+type ResultsBackendS3Spec struct {
+	// The URL of the S3 endpoint, e.g. `https://s3.eu-central-1.amazonaws.com` or `http://minio:9000`.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^https?://`
+	Endpoint string `json:"endpoint"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Bucket string `json:"bucket"`
+
+	// The prefix of the keys of the results in the bucket.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="superset/results/"
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+
+	// The region requests are signed for.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="us-east-1"
+	Region string `json:"region,omitempty"`
+
+	// Address the bucket in the path instead of the host of the endpoint, usually required by MinIO.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=false
+	PathStyle bool `json:"pathStyle,omitempty"`
+
+	// +kubebuilder:validation:Required
+	Credentials ResultsBackendS3CredentialsSpec `json:"credentials"`
+}
+
+// ResultsBackendS3CredentialsSpec defines the access key of the S3 bucket.
+// +kubebuilder:validation:XValidation:rule="has(self.secretClass) != has(self.secret)",message="exactly one of secretClass and secret must be set"
+type ResultsBackendS3CredentialsSpec struct {
+	// The SecretClass providing the files `ACCESS_KEY` and `SECRET_KEY`.
+	// +kubebuilder:validation:Optional
+	SecretClass string `json:"secretClass,omitempty"`
+
+	// The secret containing the keys `ACCESS_KEY` and `SECRET_KEY`.
+	// +kubebuilder:validation:Optional
+	Secret string `json:"secret,omitempty"`
+}
+
+// AlertsAndReportsSpec defines the alerts and reports sent by email.
+type AlertsAndReportsSpec struct {
+	// Enables the `ALERT_REPORTS` feature flag, the alerts and reports can be managed in the UI.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	Enabled *bool `json:"enabled,omitempty"`
+
+	// +kubebuilder:validation:Required
+	SMTP AlertsAndReportsSMTPSpec `json:"smtp"`
+
+	// The headless browser the workers take the screenshots of the charts and dashboards with.
+	// +kubebuilder:validation:Required
+	Webdriver *AlertsAndReportsWebdriverSpec `json:"webdriver"`
+}
+
+// AlertsAndReportsSMTPSpec defines the SMTP server the emails are sent with.
+type AlertsAndReportsSMTPSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Host string `json:"host"`
+
+	// The port of the server, e.g. 587 for `starttls` and 465 for `tls`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// +kubebuilder:default=25
+	Port int32 `json:"port,omitempty"`
+
+	// The encryption of the connection, the certificate of the server is verified with the system CAs:
+	//   - `none`: plain SMTP.
+	//   - `starttls`: the connection is upgraded with STARTTLS.
+	//   - `tls`: the connection is encrypted from the start.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=none;starttls;tls
+	// +kubebuilder:default=starttls
+	Encryption string `json:"encryption,omitempty"`
+
+	// The secret containing the keys `username` and `password`, if the server requires authentication.
+	// +kubebuilder:validation:Optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+
+	// The sender address of the emails, e.g. `superset@example.com`.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinLength=1
+	Sender string `json:"sender"`
+}
+
+// AlertsAndReportsWebdriverSpec defines the headless browser of the screenshots.
+// The superset image must provide the browser and its driver, e.g. chromium and chromedriver.
+type AlertsAndReportsWebdriverSpec struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=chrome;firefox
+	// +kubebuilder:default=chrome
+	Type string `json:"type,omitempty"`
+
+	// The URL the browser opens the charts and dashboards at, e.g. `http://<cluster>-node-<roleGroup>:8088/`.
+	// It must reach superset directly, not through an ingress with its own login.
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern=`^https?://`
+	BaseURL string `json:"baseURL"`
+
+	// The URL of superset linked in the emails, e.g. the ingress of superset. Defaults to `baseURL`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Pattern=`^https?://`
+	BaseURLUserFriendly string `json:"baseURLUserFriendly,omitempty"`
+}
+
 // AuthenticationSpec defines the authentication spec.
 type AuthenticationSpec struct {
 	// +kubebuilder:validation:Required
@@ -46,13 +476,98 @@ type AuthenticationSpec struct {
 	Oidc *OidcSpec `json:"oidc,omitempty"`
 
 	// +kubebuilder:validation:Optional
+	Ldap *LdapSpec `json:"ldap,omitempty"`
+
+	// Required by an AuthenticationClass with a kerberos provider.
+	// +kubebuilder:validation:Optional
+	Kerberos *KerberosSpec `json:"kerberos,omitempty"`
+
+	// Maps the LDAP groups or OIDC role keys of a user to superset roles, e.g.
+	// `cn=superset_admins,ou=groups,dc=example,dc=com: [Admin]`.
+	// The role keys depend on the provider hint of the OIDC provider: the `groups` claim for keycloak and okta,
+	// the `roles` claim for azure, and the teams as `<org>/<team>` for github.
+	// Users without a mapped group get the roles of `userRegistrationRole` when registered.
+	// +kubebuilder:validation:Optional
+	RoleMapping map[string][]string `json:"roleMapping,omitempty"`
+
+	// When the roles of a user are synchronized from `roleMapping`.
+	// With `Login`, the roles are replaced at every login, with `Registration` they are only set at registration.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Registration;Login
+	// +kubebuilder:default=Registration
 	SyncRolesAt string `json:"syncRolesAt,omitempty"`
 
+	// Whether users authenticated by the provider are registered in superset at their first login.
+	// When disabled, only the users already created in superset can log in.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	UserRegistration *bool `json:"userRegistration,omitempty"`
+
+	// The role of the registered users, in addition to the roles of `roleMapping`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:default=Public
+	UserRegistrationRole string `json:"userRegistrationRole,omitempty"`
+}
+
+// KerberosSpec defines the Kerberos spec. Users log in with SPNEGO, the keytab of the `HTTP` principal
+// of the node service is provisioned by the secret class set as `kerberosStorageClass` of the kerberos provider.
+// The superset image must provide the python `gssapi` package.
+type KerberosSpec struct {
+	// The realm of the KDC, e.g. `EXAMPLE.COM`.
+	// +kubebuilder:validation:Required
+	Realm string `json:"realm"`
+
+	// The host of the KDC, optionally with the port, e.g. `krb5-kdc.default.svc.cluster.local:88`.
+	// +kubebuilder:validation:Required
+	Kdc string `json:"kdc"`
+
+	// The host of the admin server, defaults to the host of the KDC.
+	// +kubebuilder:validation:Optional
+	AdminServer string `json:"adminServer,omitempty"`
+}
+
+// RemoteUserAuthenticationSpec defines the trusted header authentication spec.
+type RemoteUserAuthenticationSpec struct {
+	// The request header containing the username of the authenticated user.
+	// Superset trusts it unconditionally, so the web server must only be reachable through the proxy.
+	// Defaults to `X-Forwarded-Preferred-Username` with the proxy sidecar, `X-Forwarded-User` otherwise.
 	// +kubebuilder:validation:Optional
-	UserRegistration bool `json:"userRegistration,omitempty"`
+	// +kubebuilder:validation:Pattern=`^[A-Za-z0-9-]+$`
+	Header string `json:"header,omitempty"`
 
+	// Whether users forwarded by the proxy are registered in superset at their first login.
 	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=true
+	UserRegistration *bool `json:"userRegistration,omitempty"`
+
+	// The role of the registered users.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:MinLength=1
+	// +kubebuilder:default=Public
 	UserRegistrationRole string `json:"userRegistrationRole,omitempty"`
+
+	// Runs oauth2-proxy as sidecar of the node role, the node service then only exposes the proxy.
+	// +kubebuilder:validation:Optional
+	Proxy *AuthProxySpec `json:"proxy,omitempty"`
+}
+
+// AuthProxySpec defines the oauth2-proxy sidecar spec.
+type AuthProxySpec struct {
+	// The OIDC AuthenticationClass the proxy authenticates the users against.
+	// +kubebuilder:validation:Required
+	AuthenticationClass string `json:"authenticationClass"`
+
+	// The secret of the proxy. It must contain the following keys:
+	//   - `CLIENT_ID`: The client ID of the OIDC client.
+	//   - `CLIENT_SECRET`: The client secret of the OIDC client.
+	//   - `COOKIE_SECRET`: The seed of the session cookie, 16, 24 or 32 bytes.
+	// +kubebuilder:validation:Required
+	ClientCredentialsSecret string `json:"clientCredentialsSecret"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="quay.io/oauth2-proxy/oauth2-proxy:v7.6.0"
+	Image string `json:"image,omitempty"`
 }
 
 // OidcSpec defines the OIDC spec.
@@ -64,6 +579,16 @@ type OidcSpec struct {
 	// +kubebuilder:validation:Required
 	ClientCredentialsSecret string `json:"clientCredentialsSecret"`
 
+	// Scopes requested in addition to the default scopes of the provider hint,
+	// `openid email profile` for OIDC providers and `read:user user:email read:org` for github.
 	// +kubebuilder:validation:Optional
 	ExtraScopes []string `json:"extraScopes,omitempty"`
 }
+
+// LdapSpec defines the LDAP spec.
+type LdapSpec struct {
+	// Upgrade a plain `ldap://` connection with StartTLS instead of connecting with `ldaps://`.
+	// It only takes effect when the LDAP provider of the AuthenticationClass configures TLS.
+	// +kubebuilder:validation:Optional
+	StartTLS bool `json:"startTLS,omitempty"`
+}
@@ -13,6 +13,11 @@ type NodeSpec struct {
 
 type NodeConfigSpec struct {
 	*commonsv1alpha1.RoleGroupConfigSpec `json:",inline"`
+
+	// The listener class of the node service, overrides `clusterConfig.listenerClass`.
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=cluster-internal;external-unstable;external-stable
+	ListenerClass string `json:"listenerClass,omitempty"`
 }
 
 type NodeRoleGroupSpec struct {
@@ -22,21 +22,90 @@ import (
 )
 
 // SupersetClusterSpec defines the desired state of SupersetCluster
+// +kubebuilder:validation:XValidation:rule="!(has(self.clusterConfig.alertsAndReports) && (!has(self.worker) || !has(self.beat)))",message="alertsAndReports requires the worker and beat roles"
 type SupersetClusterSpec struct {
 	// +default:value={"repo": "quay.io/zncdatadev", "pullPolicy": "IfNotPresent"}
 	Image            *ImageSpec                        `json:"image,omitempty"`
 	ClusterConfig    *ClusterConfigSpec                `json:"clusterConfig"`
 	ClusterOperation *apiv1alpha1.ClusterOperationSpec `json:"clusterOperation,omitempty"`
 	Node             *NodeSpec                         `json:"node"`
+	// +kubebuilder:validation:Optional
+	Worker *WorkerSpec `json:"worker,omitempty"`
+	// +kubebuilder:validation:Optional
+	Beat *BeatSpec `json:"beat,omitempty"`
 }
 
+// Condition types and reasons of SupersetCluster
+const (
+	// ConditionTypeInitialized reports the outcome of the init job,
+	// which upgrades the metadata database, creates the admin user and initializes the roles.
+	ConditionTypeInitialized = "Initialized"
+	// ConditionTypeSecretsReEncrypted reports the outcome of the job
+	// which re-encrypts the stored secrets with the rotated secret key.
+	ConditionTypeSecretsReEncrypted = "SecretsReEncrypted"
+	// ConditionTypeAuthenticationValid reports whether the authentication classes can be combined.
+	ConditionTypeAuthenticationValid = "AuthenticationValid"
+
+	// ConditionTypeAvailable is true when every role group has at least one ready replica.
+	ConditionTypeAvailable = "Available"
+	// ConditionTypeProgressing is true while the latest spec is being rolled out.
+	ConditionTypeProgressing = "Progressing"
+	// ConditionTypeDegraded is true when a rolled out role group has less ready replicas than desired.
+	ConditionTypeDegraded = "Degraded"
+	// ConditionTypeReconcileFailed is true when the last reconcile returned an error.
+	ConditionTypeReconcileFailed = "ReconcileFailed"
+
+	ConditionReasonJobRunning   = "JobRunning"
+	ConditionReasonJobSucceeded = "JobSucceeded"
+	ConditionReasonJobFailed    = "JobFailed"
+
+	ConditionReasonValidAuthentication   = "ValidAuthentication"
+	ConditionReasonInvalidAuthentication = "InvalidAuthentication"
+
+	ConditionReasonReady              = "Ready"
+	ConditionReasonNotReady           = "NotReady"
+	ConditionReasonStopped            = "Stopped"
+	ConditionReasonRollingOut         = "RollingOut"
+	ConditionReasonRolledOut          = "RolledOut"
+	ConditionReasonReconcileError     = "ReconcileError"
+	ConditionReasonReconcileSucceeded = "ReconcileSucceeded"
+
+	// ConditionReasonWaitingForDependencies is set while pods wait for the metadata database or redis.
+	ConditionReasonWaitingForDependencies = "WaitingForDependencies"
+)
+
 // SupersetClusterStatus defines the observed state of SupersetCluster
 type SupersetClusterStatus struct {
+	// The generation of the spec which was last reconciled successfully.
+	// +kubebuilder:validation:Optional
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
 	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// The replicas of every role group.
+	// +kubebuilder:validation:Optional
+	RoleGroups []RoleGroupStatus `json:"roleGroups,omitempty"`
+}
+
+// RoleGroupStatus defines the observed replicas of a role group.
+type RoleGroupStatus struct {
+	Role          string `json:"role"`
+	RoleGroup     string `json:"roleGroup"`
+	Replicas      int32  `json:"replicas"`
+	ReadyReplicas int32  `json:"readyReplicas"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Available",type="string",JSONPath=".status.conditions[?(@.type==\"Available\")].status"
+// +kubebuilder:printcolumn:name="Progressing",type="string",JSONPath=".status.conditions[?(@.type==\"Progressing\")].status"
+// +kubebuilder:printcolumn:name="Degraded",type="string",JSONPath=".status.conditions[?(@.type==\"Degraded\")].status"
+// +kubebuilder:printcolumn:name="Generation",type="integer",JSONPath=".metadata.generation",priority=1
+// +kubebuilder:printcolumn:name="Observed",type="integer",JSONPath=".status.observedGeneration",priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 
 // SupersetCluster is the Schema for the supersetclusters API
 type SupersetCluster struct {
@@ -0,0 +1,29 @@
+package v1alpha1
+
+import (
+	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
+)
+
+// WorkerSpec defines the celery worker role.
+// Workers execute async SQL Lab queries, alerts, reports and cache warmup tasks,
+// and share the generated `superset_config.py` with the node role.
+// The worker concurrency defaults to 4 processes per pod, it can be changed with
+// the `CELERY_CONCURRENCY` env override.
+type WorkerSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinProperties=1
+	RoleGroups                     map[string]WorkerRoleGroupSpec  `json:"roleGroups,omitempty"`
+	Config                         *WorkerConfigSpec               `json:"config,omitempty"`
+	RoleConfig                     *commonsv1alpha1.RoleConfigSpec `json:"roleConfig,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
+
+type WorkerConfigSpec struct {
+	*commonsv1alpha1.RoleGroupConfigSpec `json:",inline"`
+}
+
+type WorkerRoleGroupSpec struct {
+	Replicas                       *int32            `json:"replicas,omitempty"`
+	Config                         *WorkerConfigSpec `json:"config,omitempty"`
+	*commonsv1alpha1.OverridesSpec `json:",inline"`
+}
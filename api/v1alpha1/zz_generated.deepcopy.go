@@ -22,11 +22,67 @@ package v1alpha1
 
 import (
 	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
-	"k8s.io/api/core/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	runtime "k8s.io/apimachinery/pkg/runtime"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertsAndReportsSMTPSpec) DeepCopyInto(out *AlertsAndReportsSMTPSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertsAndReportsSMTPSpec.
+func (in *AlertsAndReportsSMTPSpec) DeepCopy() *AlertsAndReportsSMTPSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertsAndReportsSMTPSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertsAndReportsSpec) DeepCopyInto(out *AlertsAndReportsSpec) {
+	*out = *in
+	if in.Enabled != nil {
+		in, out := &in.Enabled, &out.Enabled
+		*out = new(bool)
+		**out = **in
+	}
+	out.SMTP = in.SMTP
+	if in.Webdriver != nil {
+		in, out := &in.Webdriver, &out.Webdriver
+		*out = new(AlertsAndReportsWebdriverSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertsAndReportsSpec.
+func (in *AlertsAndReportsSpec) DeepCopy() *AlertsAndReportsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertsAndReportsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AlertsAndReportsWebdriverSpec) DeepCopyInto(out *AlertsAndReportsWebdriverSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AlertsAndReportsWebdriverSpec.
+func (in *AlertsAndReportsWebdriverSpec) DeepCopy() *AlertsAndReportsWebdriverSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AlertsAndReportsWebdriverSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AppSecretKeySpec) DeepCopyInto(out *AppSecretKeySpec) {
 	*out = *in
@@ -42,6 +98,21 @@ func (in *AppSecretKeySpec) DeepCopy() *AppSecretKeySpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthProxySpec) DeepCopyInto(out *AuthProxySpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthProxySpec.
+func (in *AuthProxySpec) DeepCopy() *AuthProxySpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthProxySpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AuthenticationSpec) DeepCopyInto(out *AuthenticationSpec) {
 	*out = *in
@@ -50,6 +121,37 @@ func (in *AuthenticationSpec) DeepCopyInto(out *AuthenticationSpec) {
 		*out = new(OidcSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Ldap != nil {
+		in, out := &in.Ldap, &out.Ldap
+		*out = new(LdapSpec)
+		**out = **in
+	}
+	if in.Kerberos != nil {
+		in, out := &in.Kerberos, &out.Kerberos
+		*out = new(KerberosSpec)
+		**out = **in
+	}
+	if in.RoleMapping != nil {
+		in, out := &in.RoleMapping, &out.RoleMapping
+		*out = make(map[string][]string, len(*in))
+		for key, val := range *in {
+			var outVal []string
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				inVal := (*in)[key]
+				in, out := &inVal, &outVal
+				*out = make([]string, len(*in))
+				copy(*out, *in)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.UserRegistration != nil {
+		in, out := &in.UserRegistration, &out.UserRegistration
+		*out = new(bool)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthenticationSpec.
@@ -62,14 +164,315 @@ func (in *AuthenticationSpec) DeepCopy() *AuthenticationSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *AuthorizationSpec) DeepCopyInto(out *AuthorizationSpec) {
+	*out = *in
+	if in.Opa != nil {
+		in, out := &in.Opa, &out.Opa
+		*out = new(OpaSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AuthorizationSpec.
+func (in *AuthorizationSpec) DeepCopy() *AuthorizationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(AuthorizationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BeatConfigSpec) DeepCopyInto(out *BeatConfigSpec) {
+	*out = *in
+	if in.RoleGroupConfigSpec != nil {
+		in, out := &in.RoleGroupConfigSpec, &out.RoleGroupConfigSpec
+		*out = new(commonsv1alpha1.RoleGroupConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BeatConfigSpec.
+func (in *BeatConfigSpec) DeepCopy() *BeatConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BeatConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BeatRoleGroupSpec) DeepCopyInto(out *BeatRoleGroupSpec) {
+	*out = *in
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(BeatConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BeatRoleGroupSpec.
+func (in *BeatRoleGroupSpec) DeepCopy() *BeatRoleGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BeatRoleGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BeatScheduleSpec) DeepCopyInto(out *BeatScheduleSpec) {
+	*out = *in
+	if in.Kwargs != nil {
+		in, out := &in.Kwargs, &out.Kwargs
+		*out = new(runtime.RawExtension)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BeatScheduleSpec.
+func (in *BeatScheduleSpec) DeepCopy() *BeatScheduleSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BeatScheduleSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *BeatSpec) DeepCopyInto(out *BeatSpec) {
+	*out = *in
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make(map[string]BeatRoleGroupSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Schedules != nil {
+		in, out := &in.Schedules, &out.Schedules
+		*out = make(map[string]BeatScheduleSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(BeatConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RoleConfig != nil {
+		in, out := &in.RoleConfig, &out.RoleConfig
+		*out = new(commonsv1alpha1.RoleConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new BeatSpec.
+func (in *BeatSpec) DeepCopy() *BeatSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(BeatSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheDatabaseIndexesSpec) DeepCopyInto(out *CacheDatabaseIndexesSpec) {
+	*out = *in
+	if in.CeleryBroker != nil {
+		in, out := &in.CeleryBroker, &out.CeleryBroker
+		*out = new(int32)
+		**out = **in
+	}
+	if in.CeleryResultBackend != nil {
+		in, out := &in.CeleryResultBackend, &out.CeleryResultBackend
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Session != nil {
+		in, out := &in.Session, &out.Session
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheDatabaseIndexesSpec.
+func (in *CacheDatabaseIndexesSpec) DeepCopy() *CacheDatabaseIndexesSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheDatabaseIndexesSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheSentinelNodeSpec) DeepCopyInto(out *CacheSentinelNodeSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheSentinelNodeSpec.
+func (in *CacheSentinelNodeSpec) DeepCopy() *CacheSentinelNodeSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheSentinelNodeSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheSentinelSpec) DeepCopyInto(out *CacheSentinelSpec) {
+	*out = *in
+	if in.Nodes != nil {
+		in, out := &in.Nodes, &out.Nodes
+		*out = make([]CacheSentinelNodeSpec, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheSentinelSpec.
+func (in *CacheSentinelSpec) DeepCopy() *CacheSentinelSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheSentinelSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheSpec) DeepCopyInto(out *CacheSpec) {
+	*out = *in
+	if in.DatabaseIndexes != nil {
+		in, out := &in.DatabaseIndexes, &out.DatabaseIndexes
+		*out = new(CacheDatabaseIndexesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Sentinel != nil {
+		in, out := &in.Sentinel, &out.Sentinel
+		*out = new(CacheSentinelSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(CacheTLSSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheSpec.
+func (in *CacheSpec) DeepCopy() *CacheSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CacheTLSSpec) DeepCopyInto(out *CacheTLSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CacheTLSSpec.
+func (in *CacheTLSSpec) DeepCopy() *CacheTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(CacheTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ClusterConfigSpec) DeepCopyInto(out *ClusterConfigSpec) {
 	*out = *in
+	if in.AuthenticationClasses != nil {
+		in, out := &in.AuthenticationClasses, &out.AuthenticationClasses
+		*out = make([]AuthenticationSpec, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	if in.Authentication != nil {
 		in, out := &in.Authentication, &out.Authentication
 		*out = new(AuthenticationSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.RemoteUserAuthentication != nil {
+		in, out := &in.RemoteUserAuthentication, &out.RemoteUserAuthentication
+		*out = new(RemoteUserAuthenticationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Authorization != nil {
+		in, out := &in.Authorization, &out.Authorization
+		*out = new(AuthorizationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AppSecretKey != nil {
+		in, out := &in.AppSecretKey, &out.AppSecretKey
+		*out = new(AppSecretKeySpec)
+		**out = **in
+	}
+	if in.MetadataDatabase != nil {
+		in, out := &in.MetadataDatabase, &out.MetadataDatabase
+		*out = new(MetadataDatabaseSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.DependencyTimeout != nil {
+		in, out := &in.DependencyTimeout, &out.DependencyTimeout
+		*out = new(v1.Duration)
+		**out = **in
+	}
+	if in.DatabaseConnectionPool != nil {
+		in, out := &in.DatabaseConnectionPool, &out.DatabaseConnectionPool
+		*out = new(DatabaseConnectionPoolSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cache != nil {
+		in, out := &in.Cache, &out.Cache
+		*out = new(CacheSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Session != nil {
+		in, out := &in.Session, &out.Session
+		*out = new(SessionSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResultsBackend != nil {
+		in, out := &in.ResultsBackend, &out.ResultsBackend
+		*out = new(ResultsBackendSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.AlertsAndReports != nil {
+		in, out := &in.AlertsAndReports, &out.AlertsAndReports
+		*out = new(AlertsAndReportsSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ClusterConfigSpec.
@@ -82,12 +485,47 @@ func (in *ClusterConfigSpec) DeepCopy() *ClusterConfigSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DatabaseConnectionPoolSpec) DeepCopyInto(out *DatabaseConnectionPoolSpec) {
+	*out = *in
+	if in.PoolSize != nil {
+		in, out := &in.PoolSize, &out.PoolSize
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxOverflow != nil {
+		in, out := &in.MaxOverflow, &out.MaxOverflow
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PoolRecycleSeconds != nil {
+		in, out := &in.PoolRecycleSeconds, &out.PoolRecycleSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PoolPrePing != nil {
+		in, out := &in.PoolPrePing, &out.PoolPrePing
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DatabaseConnectionPoolSpec.
+func (in *DatabaseConnectionPoolSpec) DeepCopy() *DatabaseConnectionPoolSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(DatabaseConnectionPoolSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ImageSpec) DeepCopyInto(out *ImageSpec) {
 	*out = *in
 	if in.PullPolicy != nil {
 		in, out := &in.PullPolicy, &out.PullPolicy
-		*out = new(v1.PullPolicy)
+		*out = new(corev1.PullPolicy)
 		**out = **in
 	}
 }
@@ -102,6 +540,71 @@ func (in *ImageSpec) DeepCopy() *ImageSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *KerberosSpec) DeepCopyInto(out *KerberosSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new KerberosSpec.
+func (in *KerberosSpec) DeepCopy() *KerberosSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(KerberosSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LdapSpec) DeepCopyInto(out *LdapSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LdapSpec.
+func (in *LdapSpec) DeepCopy() *LdapSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LdapSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataDatabaseSpec) DeepCopyInto(out *MetadataDatabaseSpec) {
+	*out = *in
+	if in.TLS != nil {
+		in, out := &in.TLS, &out.TLS
+		*out = new(MetadataDatabaseTLSSpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataDatabaseSpec.
+func (in *MetadataDatabaseSpec) DeepCopy() *MetadataDatabaseSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataDatabaseSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *MetadataDatabaseTLSSpec) DeepCopyInto(out *MetadataDatabaseTLSSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new MetadataDatabaseTLSSpec.
+func (in *MetadataDatabaseTLSSpec) DeepCopy() *MetadataDatabaseTLSSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(MetadataDatabaseTLSSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *NodeConfigSpec) DeepCopyInto(out *NodeConfigSpec) {
 	*out = *in
@@ -209,6 +712,138 @@ func (in *OidcSpec) DeepCopy() *OidcSpec {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *OpaSpec) DeepCopyInto(out *OpaSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new OpaSpec.
+func (in *OpaSpec) DeepCopy() *OpaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(OpaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RemoteUserAuthenticationSpec) DeepCopyInto(out *RemoteUserAuthenticationSpec) {
+	*out = *in
+	if in.UserRegistration != nil {
+		in, out := &in.UserRegistration, &out.UserRegistration
+		*out = new(bool)
+		**out = **in
+	}
+	if in.Proxy != nil {
+		in, out := &in.Proxy, &out.Proxy
+		*out = new(AuthProxySpec)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RemoteUserAuthenticationSpec.
+func (in *RemoteUserAuthenticationSpec) DeepCopy() *RemoteUserAuthenticationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(RemoteUserAuthenticationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResultsBackendS3CredentialsSpec) DeepCopyInto(out *ResultsBackendS3CredentialsSpec) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResultsBackendS3CredentialsSpec.
+func (in *ResultsBackendS3CredentialsSpec) DeepCopy() *ResultsBackendS3CredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResultsBackendS3CredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResultsBackendS3Spec) DeepCopyInto(out *ResultsBackendS3Spec) {
+	*out = *in
+	out.Credentials = in.Credentials
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResultsBackendS3Spec.
+func (in *ResultsBackendS3Spec) DeepCopy() *ResultsBackendS3Spec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResultsBackendS3Spec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ResultsBackendSpec) DeepCopyInto(out *ResultsBackendSpec) {
+	*out = *in
+	out.S3 = in.S3
+	if in.GlobalAsyncQueries != nil {
+		in, out := &in.GlobalAsyncQueries, &out.GlobalAsyncQueries
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ResultsBackendSpec.
+func (in *ResultsBackendSpec) DeepCopy() *ResultsBackendSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ResultsBackendSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RoleGroupStatus) DeepCopyInto(out *RoleGroupStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RoleGroupStatus.
+func (in *RoleGroupStatus) DeepCopy() *RoleGroupStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RoleGroupStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SessionSpec) DeepCopyInto(out *SessionSpec) {
+	*out = *in
+	if in.CookieSecure != nil {
+		in, out := &in.CookieSecure, &out.CookieSecure
+		*out = new(bool)
+		**out = **in
+	}
+	if in.CookieHTTPOnly != nil {
+		in, out := &in.CookieHTTPOnly, &out.CookieHTTPOnly
+		*out = new(bool)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SessionSpec.
+func (in *SessionSpec) DeepCopy() *SessionSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(SessionSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SupersetCluster) DeepCopyInto(out *SupersetCluster) {
 	*out = *in
@@ -291,6 +926,16 @@ func (in *SupersetClusterSpec) DeepCopyInto(out *SupersetClusterSpec) {
 		*out = new(NodeSpec)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Worker != nil {
+		in, out := &in.Worker, &out.Worker
+		*out = new(WorkerSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Beat != nil {
+		in, out := &in.Beat, &out.Beat
+		*out = new(BeatSpec)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SupersetClusterSpec.
@@ -308,11 +953,16 @@ func (in *SupersetClusterStatus) DeepCopyInto(out *SupersetClusterStatus) {
 	*out = *in
 	if in.Conditions != nil {
 		in, out := &in.Conditions, &out.Conditions
-		*out = make([]metav1.Condition, len(*in))
+		*out = make([]v1.Condition, len(*in))
 		for i := range *in {
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make([]RoleGroupStatus, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SupersetClusterStatus.
@@ -324,3 +974,90 @@ func (in *SupersetClusterStatus) DeepCopy() *SupersetClusterStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerConfigSpec) DeepCopyInto(out *WorkerConfigSpec) {
+	*out = *in
+	if in.RoleGroupConfigSpec != nil {
+		in, out := &in.RoleGroupConfigSpec, &out.RoleGroupConfigSpec
+		*out = new(commonsv1alpha1.RoleGroupConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerConfigSpec.
+func (in *WorkerConfigSpec) DeepCopy() *WorkerConfigSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerConfigSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerRoleGroupSpec) DeepCopyInto(out *WorkerRoleGroupSpec) {
+	*out = *in
+	if in.Replicas != nil {
+		in, out := &in.Replicas, &out.Replicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(WorkerConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerRoleGroupSpec.
+func (in *WorkerRoleGroupSpec) DeepCopy() *WorkerRoleGroupSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerRoleGroupSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WorkerSpec) DeepCopyInto(out *WorkerSpec) {
+	*out = *in
+	if in.RoleGroups != nil {
+		in, out := &in.RoleGroups, &out.RoleGroups
+		*out = make(map[string]WorkerRoleGroupSpec, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Config != nil {
+		in, out := &in.Config, &out.Config
+		*out = new(WorkerConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.RoleConfig != nil {
+		in, out := &in.RoleConfig, &out.RoleConfig
+		*out = new(commonsv1alpha1.RoleConfigSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.OverridesSpec != nil {
+		in, out := &in.OverridesSpec, &out.OverridesSpec
+		*out = new(commonsv1alpha1.OverridesSpec)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WorkerSpec.
+func (in *WorkerSpec) DeepCopy() *WorkerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(WorkerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
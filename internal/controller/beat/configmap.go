@@ -0,0 +1,32 @@
+package beat
+
+import (
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+	"github.com/zncdatadev/superset-operator/internal/controller/common"
+)
+
+// NewConfigReconciler returns the superset config reconciler of the beat role group,
+// which additionally renders the celery beat schedules.
+func NewConfigReconciler(
+	client *client.Client,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+	roleGroupInfo reconciler.RoleGroupInfo,
+	schedules map[string]supersetv1alpha1.BeatScheduleSpec,
+) *reconciler.SimpleResourceReconciler[builder.ConfigBuilder] {
+
+	supersetConfigBuilder := common.NewSupersetConfigBuilder(
+		client,
+		roleGroupInfo,
+		clusterConfig,
+	)
+	supersetConfigBuilder.BeatSchedules = schedules
+
+	return reconciler.NewSimpleResourceReconciler[builder.ConfigBuilder](
+		client,
+		supersetConfigBuilder,
+	)
+}
@@ -6,8 +6,14 @@ import (
 	resourceClient "github.com/zncdatadev/operator-go/pkg/client"
 	"github.com/zncdatadev/operator-go/pkg/reconciler"
 	"github.com/zncdatadev/operator-go/pkg/util"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
 	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+	"github.com/zncdatadev/superset-operator/internal/controller/beat"
+	"github.com/zncdatadev/superset-operator/internal/controller/common"
+	"github.com/zncdatadev/superset-operator/internal/controller/job"
 	"github.com/zncdatadev/superset-operator/internal/controller/node"
+	"github.com/zncdatadev/superset-operator/internal/controller/worker"
 	"github.com/zncdatadev/superset-operator/internal/util/version"
 )
 
@@ -16,6 +22,10 @@ var _ reconciler.Reconciler = &Reconciler{}
 type Reconciler struct {
 	reconciler.BaseCluster[*supersetv1alpha1.SupersetClusterSpec]
 	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
+
+	authentication *common.AuthenticationReconciler
+	initJob        *job.InitReconciler
+	reEncryptJob   *job.ReEncryptReconciler
 }
 
 func NewReconciler(
@@ -60,15 +70,62 @@ func (r *Reconciler) GetImage() *util.Image {
 	return image
 }
 
+// GetConditions returns the conditions observed by the registered resources during Reconcile.
+func (r *Reconciler) GetConditions() []metav1.Condition {
+	var conditions []metav1.Condition
+	if r.authentication != nil && r.authentication.GetCondition() != nil {
+		conditions = append(conditions, *r.authentication.GetCondition())
+	}
+	if r.initJob != nil && r.initJob.GetCondition() != nil {
+		conditions = append(conditions, *r.initJob.GetCondition())
+	}
+	if r.reEncryptJob != nil && r.reEncryptJob.GetCondition() != nil {
+		conditions = append(conditions, *r.reEncryptJob.GetCondition())
+	}
+	return conditions
+}
+
 func (r *Reconciler) RegisterResources(ctx context.Context) error {
 
+	// Invalid authentication classes stop the reconcile before any config is rendered with them.
+	r.authentication = common.NewAuthenticationReconciler(r.Client, r.ClusterConfig)
+	r.AddResource(r.authentication)
+
+	// The secret key is used by every pod, so it is reconciled before any of them.
+	r.AddResource(common.NewSecretKeyReconciler(
+		r.Client,
+		r.ClusterInfo,
+		r.ClusterConfig,
+	))
+
+	// The init job is registered first, the role groups are not reconciled until it succeeded.
+	// A stopped cluster does not run any pod, so the job is skipped.
+	if !r.IsStopped() {
+		r.initJob = job.NewInitReconciler(
+			r.Client,
+			r.ClusterInfo,
+			r.ClusterConfig,
+			r.GetImage(),
+		)
+		r.AddResource(r.initJob)
+
+		// After a secret key rotation, the stored secrets are re-encrypted before the role groups are rolled.
+		r.reEncryptJob = job.NewReEncryptReconciler(
+			r.Client,
+			r.ClusterInfo,
+			r.ClusterConfig,
+			r.GetImage(),
+		)
+		r.AddResource(r.reEncryptJob)
+	}
+
 	node := node.NewReconciler(
 		r.Client,
 		r.IsStopped(),
 		r.ClusterConfig,
 		reconciler.RoleInfo{
 			ClusterInfo: r.ClusterInfo,
-			RoleName:    "node",
+			RoleName:    common.NodeRoleName,
 		},
 		r.GetImage(),
 		r.Spec.Node,
@@ -80,6 +137,46 @@ func (r *Reconciler) RegisterResources(ctx context.Context) error {
 
 	r.AddResource(node)
 
+	if r.Spec.Worker != nil {
+		worker := worker.NewReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    common.WorkerRoleName,
+			},
+			r.GetImage(),
+			r.Spec.Worker,
+		)
+
+		if err := worker.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(worker)
+	}
+
+	if r.Spec.Beat != nil {
+		beat := beat.NewReconciler(
+			r.Client,
+			r.IsStopped(),
+			r.ClusterConfig,
+			reconciler.RoleInfo{
+				ClusterInfo: r.ClusterInfo,
+				RoleName:    common.BeatRoleName,
+			},
+			r.GetImage(),
+			r.Spec.Beat,
+		)
+
+		if err := beat.RegisterResources(ctx); err != nil {
+			return err
+		}
+
+		r.AddResource(beat)
+	}
+
 	return nil
 
 }
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"strconv"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	SMTPEncryptionNone     = "none"
+	SMTPEncryptionStartTLS = "starttls"
+	SMTPEncryptionTLS      = "tls"
+
+	DefaultSMTPPort       = 25
+	DefaultSMTPEncryption = SMTPEncryptionStartTLS
+
+	// The keys of the SMTP credentials secret.
+	SMTPUsernameSecretKey = "username"
+	SMTPPasswordSecretKey = "password"
+
+	SMTPUsernameEnv = "SMTP_USER"
+	SMTPPasswordEnv = "SMTP_PASSWORD"
+
+	WebdriverTypeChrome  = "chrome"
+	WebdriverTypeFirefox = "firefox"
+)
+
+// InjectAlertsAndReports adds the env vars of the SMTP credentials.
+func InjectAlertsAndReports(alertsAndReports *supersetv1alpha1.AlertsAndReportsSpec, builder builder.ContainerBuilder) {
+	secret := alertsAndReports.SMTP.CredentialsSecret
+	if secret == "" {
+		return
+	}
+	secretKeyRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key:                  key,
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+			},
+		}
+	}
+	builder.AddEnvVars([]corev1.EnvVar{
+		{Name: SMTPUsernameEnv, ValueFrom: secretKeyRef(SMTPUsernameSecretKey)},
+		{Name: SMTPPasswordEnv, ValueFrom: secretKeyRef(SMTPPasswordSecretKey)},
+	})
+}
+
+// getAlertsAndReportsConfig renders the feature flag of the alerts and reports, the SMTP server
+// the emails are sent with and the browser of the screenshots.
+// Superset only logs in to the SMTP server if the credentials are set.
+func (b *SupersetConfigMapBuilder) getAlertsAndReportsConfig(alertsAndReports *supersetv1alpha1.AlertsAndReportsSpec) string {
+	smtp := alertsAndReports.SMTP
+	enabled := alertsAndReports.Enabled == nil || *alertsAndReports.Enabled
+	port := smtp.Port
+	if port == 0 {
+		port = DefaultSMTPPort
+	}
+	encryption := smtp.Encryption
+	if encryption == "" {
+		encryption = DefaultSMTPEncryption
+	}
+
+	return `
+# Send alerts and reports by email, they are scheduled by the beat role and run by the workers
+FEATURE_FLAGS['ALERT_REPORTS'] = ` + pythonBool(enabled) + `
+
+SMTP_HOST = ` + strconv.Quote(smtp.Host) + `
+SMTP_PORT = ` + strconv.Itoa(int(port)) + `
+SMTP_STARTTLS = ` + pythonBool(encryption == SMTPEncryptionStartTLS) + `
+SMTP_SSL = ` + pythonBool(encryption == SMTPEncryptionTLS) + `
+SMTP_SSL_SERVER_AUTH = ` + pythonBool(encryption != SMTPEncryptionNone) + `
+SMTP_USER = os.environ.get('` + SMTPUsernameEnv + `')
+SMTP_PASSWORD = os.environ.get('` + SMTPPasswordEnv + `')
+SMTP_MAIL_FROM = ` + strconv.Quote(smtp.Sender) + `
+` + b.getWebdriverConfig(alertsAndReports.Webdriver)
+}
+
+// getWebdriverConfig renders the headless browser the workers take the screenshots with.
+// The browser logs in with a session cookie of the report owner, so it works with every authentication type
+// but the auth proxy, which the CRD rejects.
+// Chrome is run without sandbox, as the container does not allow it, and without /dev/shm, which is too small.
+func (b *SupersetConfigMapBuilder) getWebdriverConfig(webdriver *supersetv1alpha1.AlertsAndReportsWebdriverSpec) string {
+	webdriverType := WebdriverTypeChrome
+	var baseURL, baseURLUserFriendly string
+	if webdriver != nil {
+		if webdriver.Type != "" {
+			webdriverType = webdriver.Type
+		}
+		baseURL = webdriver.BaseURL
+		baseURLUserFriendly = webdriver.BaseURLUserFriendly
+	}
+	if baseURLUserFriendly == "" {
+		baseURLUserFriendly = baseURL
+	}
+
+	optionArgs := []string{"--headless"}
+	if webdriverType == WebdriverTypeChrome {
+		optionArgs = append(optionArgs, "--no-sandbox", "--disable-dev-shm-usage", "--disable-gpu")
+	}
+
+	return `
+# Take the screenshots of the charts and dashboards with a headless browser
+WEBDRIVER_TYPE = ` + strconv.Quote(webdriverType) + `
+WEBDRIVER_OPTION_ARGS = ` + pythonStringList(optionArgs) + `
+WEBDRIVER_BASEURL = ` + strconv.Quote(baseURL) + `
+WEBDRIVER_BASEURL_USER_FRIENDLY = ` + strconv.Quote(baseURLUserFriendly) + `
+`
+}
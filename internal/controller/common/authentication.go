@@ -0,0 +1,297 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+// AuthenticationMethod is an authentication spec with the provider of its authentication class.
+type AuthenticationMethod struct {
+	Spec     *supersetv1alpha1.AuthenticationSpec
+	Provider *authv1alpha1.AuthenticationProvider
+}
+
+// Authentication is the resolved authentication of a cluster, in the order of the spec.
+type Authentication struct {
+	Methods []AuthenticationMethod
+}
+
+// ResolveAuthentication gets the authentication class of every authentication spec,
+// it returns nil if authentication is disabled.
+func ResolveAuthentication(
+	ctx context.Context,
+	client *client.Client,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+) (*Authentication, error) {
+	specs := getAuthenticationSpecs(clusterConfig)
+	if len(specs) == 0 {
+		return nil, nil
+	}
+
+	authentication := &Authentication{}
+	for _, spec := range specs {
+		authClass := &authv1alpha1.AuthenticationClass{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      spec.AuthenticationClass,
+				Namespace: client.GetOwnerNamespace(),
+			},
+		}
+		if err := client.GetWithObject(ctx, authClass); err != nil {
+			return nil, err
+		}
+		if authClass.Spec.AuthenticationProvider == nil {
+			return nil, fmt.Errorf("authentication class %s has no provider", spec.AuthenticationClass)
+		}
+		authentication.Methods = append(authentication.Methods, AuthenticationMethod{
+			Spec:     spec,
+			Provider: authClass.Spec.AuthenticationProvider,
+		})
+	}
+	return authentication, nil
+}
+
+// getAuthenticationSpecs returns the authentication classes of the spec,
+// the deprecated single authentication class is returned as a list of one entry.
+func getAuthenticationSpecs(clusterConfig *supersetv1alpha1.ClusterConfigSpec) []*supersetv1alpha1.AuthenticationSpec {
+	if clusterConfig == nil {
+		return nil
+	}
+	if clusterConfig.Authentication != nil {
+		return []*supersetv1alpha1.AuthenticationSpec{clusterConfig.Authentication}
+	}
+	specs := make([]*supersetv1alpha1.AuthenticationSpec, 0, len(clusterConfig.AuthenticationClasses))
+	for i := range clusterConfig.AuthenticationClasses {
+		specs = append(specs, &clusterConfig.AuthenticationClasses[i])
+	}
+	return specs
+}
+
+// GetLDAP returns the LDAP method, nil if there is none.
+func (a *Authentication) GetLDAP() *AuthenticationMethod {
+	for i := range a.Methods {
+		if a.Methods[i].Provider != nil && a.Methods[i].Provider.LDAP != nil {
+			return &a.Methods[i]
+		}
+	}
+	return nil
+}
+
+// GetKerberos returns the Kerberos method, nil if there is none.
+func (a *Authentication) GetKerberos() *AuthenticationMethod {
+	for i := range a.Methods {
+		if a.Methods[i].Provider != nil && a.Methods[i].Provider.Kerberos != nil {
+			return &a.Methods[i]
+		}
+	}
+	return nil
+}
+
+// GetOIDC returns the OIDC methods, they are rendered as OAuth providers in the order of the spec.
+func (a *Authentication) GetOIDC() []AuthenticationMethod {
+	var methods []AuthenticationMethod
+	for _, method := range a.Methods {
+		if method.Provider != nil && method.Provider.OIDC != nil {
+			methods = append(methods, method)
+		}
+	}
+	return methods
+}
+
+// GetSettings returns the spec holding the settings shared by every method,
+// e.g. user registration, Validate ensures they are the same in every spec.
+func (a *Authentication) GetSettings() *supersetv1alpha1.AuthenticationSpec {
+	return a.Methods[0].Spec
+}
+
+// GetRoleMapping returns the role mappings of every method merged.
+func (a *Authentication) GetRoleMapping() map[string][]string {
+	roleMapping := map[string][]string{}
+	for _, method := range a.Methods {
+		for group, roles := range method.Spec.RoleMapping {
+			for _, role := range roles {
+				if !slices.Contains(roleMapping[group], role) {
+					roleMapping[group] = append(roleMapping[group], role)
+				}
+			}
+		}
+	}
+	return roleMapping
+}
+
+// Validate returns an error for the combinations superset can not render.
+// Superset has a single AUTH_TYPE, so only several OIDC providers can be combined.
+func (a *Authentication) Validate() error {
+	var ldap, oidc, kerberos []string
+	oidcEnvNames := map[string]string{}
+	for _, method := range a.Methods {
+		name := method.Spec.AuthenticationClass
+		switch {
+		case method.Provider == nil:
+			return fmt.Errorf("authentication class %s has no provider", name)
+		case method.Provider.LDAP != nil:
+			ldap = append(ldap, name)
+		case method.Provider.OIDC != nil:
+			if method.Spec.Oidc == nil {
+				return fmt.Errorf("authentication class %s is an OIDC provider, but oidc.clientCredentialsSecret is not set", name)
+			}
+			if err := validateProviderHint(name, method.Provider.OIDC); err != nil {
+				return err
+			}
+			envName, _ := GetOIDCClientEnvNames(name)
+			if other, ok := oidcEnvNames[envName]; ok {
+				return fmt.Errorf("OIDC authentication classes %s and %s have the same client credentials env vars, rename one of them",
+					other, name)
+			}
+			oidcEnvNames[envName] = name
+			oidc = append(oidc, name)
+		case method.Provider.Kerberos != nil:
+			if method.Spec.Kerberos == nil {
+				return fmt.Errorf("authentication class %s is a Kerberos provider, but kerberos.realm and kerberos.kdc are not set", name)
+			}
+			kerberos = append(kerberos, name)
+		default:
+			return fmt.Errorf("authentication class %s has an unsupported provider, only LDAP, OIDC and Kerberos are supported", name)
+		}
+	}
+
+	if len(ldap) > 1 {
+		return fmt.Errorf("only one LDAP authentication class is supported, got %s", strings.Join(ldap, ", "))
+	}
+	if len(kerberos) > 1 {
+		return fmt.Errorf("only one Kerberos authentication class is supported, got %s", strings.Join(kerberos, ", "))
+	}
+	if len(kerberos) > 0 && len(ldap)+len(oidc) > 0 {
+		return fmt.Errorf("authentication class %s is a Kerberos provider, it can not be combined with other authentication classes",
+			kerberos[0])
+	}
+	if len(ldap) > 0 && len(oidc) > 0 {
+		return fmt.Errorf("LDAP authentication class %s can not be combined with OIDC authentication classes %s",
+			ldap[0], strings.Join(oidc, ", "))
+	}
+
+	settings := a.GetSettings()
+	for _, method := range a.Methods[1:] {
+		spec := method.Spec
+		if spec.SyncRolesAt != settings.SyncRolesAt ||
+			spec.UserRegistrationRole != settings.UserRegistrationRole ||
+			isUserRegistration(spec) != isUserRegistration(settings) {
+			return fmt.Errorf("userRegistration, userRegistrationRole and syncRolesAt of authentication class %s "+
+				"must be the same as of %s", spec.AuthenticationClass, settings.AuthenticationClass)
+		}
+	}
+	return nil
+}
+
+func isUserRegistration(spec *supersetv1alpha1.AuthenticationSpec) bool {
+	return spec.UserRegistration == nil || *spec.UserRegistration
+}
+
+var envNameInvalidChars = regexp.MustCompile(`[^A-Z0-9]+`)
+
+// GetOIDCClientEnvNames returns the env var names of the client id and secret of an OIDC authentication class,
+// every OIDC provider has its own client credentials.
+func GetOIDCClientEnvNames(authenticationClass string) (clientID string, clientSecret string) {
+	prefix := "OIDC_" + envNameInvalidChars.ReplaceAllString(strings.ToUpper(authenticationClass), "_")
+	return prefix + "_CLIENT_ID", prefix + "_CLIENT_SECRET"
+}
+
+var _ reconciler.Reconciler = &AuthenticationReconciler{}
+
+// AuthenticationReconciler validates the authentication classes before any resource using them is reconciled,
+// the outcome is reported as the AuthenticationValid condition.
+type AuthenticationReconciler struct {
+	Client        *client.Client
+	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
+
+	condition *metav1.Condition
+}
+
+func NewAuthenticationReconciler(
+	client *client.Client,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+) *AuthenticationReconciler {
+	return &AuthenticationReconciler{
+		Client:        client,
+		ClusterConfig: clusterConfig,
+	}
+}
+
+func (r *AuthenticationReconciler) GetName() string {
+	return r.Client.GetOwnerName()
+}
+
+func (r *AuthenticationReconciler) GetNamespace() string {
+	return r.Client.GetOwnerNamespace()
+}
+
+func (r *AuthenticationReconciler) GetClient() *client.Client {
+	return r.Client
+}
+
+// GetCondition returns the AuthenticationValid condition observed by the last Reconcile, nil if not reconciled yet.
+func (r *AuthenticationReconciler) GetCondition() *metav1.Condition {
+	return r.condition
+}
+
+func (r *AuthenticationReconciler) setCondition(status metav1.ConditionStatus, reason, message string) {
+	r.condition = &metav1.Condition{
+		Type:    supersetv1alpha1.ConditionTypeAuthenticationValid,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func (r *AuthenticationReconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	authentication, err := ResolveAuthentication(ctx, r.Client, r.ClusterConfig)
+	if err != nil {
+		r.setCondition(metav1.ConditionFalse, supersetv1alpha1.ConditionReasonInvalidAuthentication, err.Error())
+		return ctrl.Result{}, err
+	}
+
+	if r.ClusterConfig.RemoteUserAuthentication != nil {
+		if authentication != nil {
+			err := fmt.Errorf("remoteUserAuthentication can not be combined with authentication classes")
+			r.setCondition(metav1.ConditionFalse, supersetv1alpha1.ConditionReasonInvalidAuthentication, err.Error())
+			return ctrl.Result{}, fmt.Errorf("invalid authentication: %w", err)
+		}
+		if _, err := ResolveAuthProxyProvider(ctx, r.Client, r.ClusterConfig); err != nil {
+			r.setCondition(metav1.ConditionFalse, supersetv1alpha1.ConditionReasonInvalidAuthentication, err.Error())
+			return ctrl.Result{}, fmt.Errorf("invalid authentication: %w", err)
+		}
+		r.setCondition(metav1.ConditionTrue, supersetv1alpha1.ConditionReasonValidAuthentication,
+			"Users are authenticated by the proxy setting the header "+GetRemoteUserHeader(r.ClusterConfig.RemoteUserAuthentication))
+		return ctrl.Result{}, nil
+	}
+
+	if authentication == nil {
+		r.setCondition(metav1.ConditionTrue, supersetv1alpha1.ConditionReasonValidAuthentication,
+			"Authentication is disabled, users log in with the superset database")
+		return ctrl.Result{}, nil
+	}
+
+	if err := authentication.Validate(); err != nil {
+		r.setCondition(metav1.ConditionFalse, supersetv1alpha1.ConditionReasonInvalidAuthentication, err.Error())
+		return ctrl.Result{}, fmt.Errorf("invalid authentication: %w", err)
+	}
+
+	r.setCondition(metav1.ConditionTrue, supersetv1alpha1.ConditionReasonValidAuthentication,
+		fmt.Sprintf("%d authentication classes are valid", len(authentication.Methods)))
+	return ctrl.Result{}, nil
+}
+
+// Ready always returns an empty result, there is nothing to wait for.
+func (r *AuthenticationReconciler) Ready(ctx context.Context) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,274 @@
+package common
+
+import (
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	DefaultRedisPort = 6379
+
+	// The keys of the redis password secret, the username is optional.
+	RedisUsernameSecretKey = "username"
+	RedisPasswordSecretKey = "password"
+
+	RedisUsernameEnv         = "REDIS_USERNAME"
+	RedisPasswordEnv         = "REDIS_PASSWORD"
+	RedisSentinelPasswordEnv = "REDIS_SENTINEL_PASSWORD"
+
+	DefaultRedisSentinelPort = 26379
+	RedisTLSVolumeName       = "redis-tls"
+
+	DefaultRedisCeleryBrokerDB        = 0
+	DefaultRedisCeleryResultBackendDB = 1
+	DefaultRedisCacheDB               = 2
+	DefaultRedisSessionDB             = 3
+)
+
+// InjectCache adds the env vars of the redis and sentinel credentials.
+func InjectCache(cache *supersetv1alpha1.CacheSpec, builder builder.ContainerBuilder) {
+	secretKeyRef := func(name, key string, optional bool) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key:                  key,
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				Optional:             &optional,
+			},
+		}
+	}
+
+	if cache.PasswordSecret != "" {
+		builder.AddEnvVars([]corev1.EnvVar{
+			{Name: RedisUsernameEnv, ValueFrom: secretKeyRef(cache.PasswordSecret, RedisUsernameSecretKey, true)},
+			{Name: RedisPasswordEnv, ValueFrom: secretKeyRef(cache.PasswordSecret, RedisPasswordSecretKey, false)},
+		})
+	}
+	if cache.Sentinel != nil && cache.Sentinel.PasswordSecret != "" {
+		builder.AddEnvVars([]corev1.EnvVar{
+			{Name: RedisSentinelPasswordEnv, ValueFrom: secretKeyRef(cache.Sentinel.PasswordSecret, RedisPasswordSecretKey, false)},
+		})
+	}
+}
+
+// NewRedisTLSVolume returns the volume of the CA of redis, nil if the system CAs are used.
+func NewRedisTLSVolume(cache *supersetv1alpha1.CacheSpec) (*corev1.Volume, *corev1.VolumeMount) {
+	if cache == nil || cache.TLS == nil || cache.TLS.CASecretClass == "" {
+		return nil, nil
+	}
+	return NewSecretVolume(RedisTLSVolumeName, cache.TLS.CASecretClass, nil, nil)
+}
+
+// addRedisTLS mounts the CA of redis.
+func (b *StatefulSetBuilder) addRedisTLS(container builder.ContainerBuilder, cache *supersetv1alpha1.CacheSpec) {
+	b.addSecretVolume(container, RedisTLSVolumeName, cache.TLS.CASecretClass, nil)
+}
+
+// getRedisPort returns the port of redis, the default port if not set.
+func getRedisPort(cache *supersetv1alpha1.CacheSpec) int32 {
+	if cache.Port != 0 {
+		return cache.Port
+	}
+	return DefaultRedisPort
+}
+
+// getRedisSentinelPort returns the port of the sentinel, the default port if not set.
+func getRedisSentinelPort(node supersetv1alpha1.CacheSentinelNodeSpec) int32 {
+	if node.Port != 0 {
+		return node.Port
+	}
+	return DefaultRedisSentinelPort
+}
+
+// redisDBs are the redis databases of the celery broker, the celery result backend, the caches and the sessions.
+type redisDBs struct {
+	broker        int32
+	resultBackend int32
+	cache         int32
+	session       int32
+}
+
+// getRedisDBs returns the redis databases, the defaults for the unset indexes.
+func getRedisDBs(cache *supersetv1alpha1.CacheSpec) redisDBs {
+	dbs := redisDBs{
+		broker:        DefaultRedisCeleryBrokerDB,
+		resultBackend: DefaultRedisCeleryResultBackendDB,
+		cache:         DefaultRedisCacheDB,
+		session:       DefaultRedisSessionDB,
+	}
+	if indexes := cache.DatabaseIndexes; indexes != nil {
+		if indexes.CeleryBroker != nil {
+			dbs.broker = *indexes.CeleryBroker
+		}
+		if indexes.CeleryResultBackend != nil {
+			dbs.resultBackend = *indexes.CeleryResultBackend
+		}
+		if indexes.Cache != nil {
+			dbs.cache = *indexes.Cache
+		}
+		if indexes.Session != nil {
+			dbs.session = *indexes.Session
+		}
+	}
+	return dbs
+}
+
+// getRedisSSLOptions renders the python dict of the TLS options of the redis connections,
+// the keys are understood by redis-py, celery and as query parameters of a redis URL.
+func getRedisSSLOptions(cache *supersetv1alpha1.CacheSpec) string {
+	if cache.TLS == nil {
+		return "{}"
+	}
+	if cache.TLS.CASecretClass == "" {
+		return "{'ssl_cert_reqs': 'required'}"
+	}
+	return "{'ssl_cert_reqs': 'required', 'ssl_ca_certs': " +
+		strconv.Quote(path.Join(constants.KubedoopSecretDir, cache.TLS.CASecretClass, "ca.crt")) + "}"
+}
+
+// getCacheConfig renders the caches of superset in redis. They share a database and are separated
+// by key prefixes, the timeouts are the defaults of superset.
+// get_redis_url renders the URLs of celery, the credentials are escaped so they can contain any character.
+func (b *SupersetConfigMapBuilder) getCacheConfig(cache *supersetv1alpha1.CacheSpec) string {
+	config := `
+# Redis shared by the node replicas as cache, and by celery as broker and result backend
+from urllib.parse import quote, urlencode
+
+REDIS_USERNAME = os.environ.get('` + RedisUsernameEnv + `')
+REDIS_PASSWORD = os.environ.get('` + RedisPasswordEnv + `')
+REDIS_SSL_OPTIONS = ` + getRedisSSLOptions(cache) + `
+
+
+def get_redis_credentials():
+	if not REDIS_PASSWORD:
+		return ''
+	return '{}:{}@'.format(quote(REDIS_USERNAME or '', safe=''), quote(REDIS_PASSWORD, safe=''))
+
+`
+	if cache.Sentinel != nil {
+		config += b.getRedisSentinelConfig(cache)
+	} else {
+		config += b.getRedisConfig(cache)
+	}
+
+	return config + `
+DATA_CACHE_CONFIG = {
+	**CACHE_CONFIG,
+	'CACHE_DEFAULT_TIMEOUT': 86400,
+	'CACHE_KEY_PREFIX': 'superset_data_',
+}
+FILTER_STATE_CACHE_CONFIG = {
+	**CACHE_CONFIG,
+	'CACHE_DEFAULT_TIMEOUT': 7776000,
+	'CACHE_KEY_PREFIX': 'superset_filter_state_',
+}
+EXPLORE_FORM_DATA_CACHE_CONFIG = {
+	**CACHE_CONFIG,
+	'CACHE_DEFAULT_TIMEOUT': 604800,
+	'CACHE_KEY_PREFIX': 'superset_explore_form_data_',
+}
+`
+}
+
+// getRedisConfig renders the cache of a single redis, the TLS options are passed as query of the URL.
+func (b *SupersetConfigMapBuilder) getRedisConfig(cache *supersetv1alpha1.CacheSpec) string {
+	scheme := "redis"
+	if cache.TLS != nil {
+		scheme = "rediss"
+	}
+	cacheDB := getRedisDBs(cache).cache
+
+	return `
+REDIS_HOST = ` + strconv.Quote(cache.Host) + `
+REDIS_PORT = ` + strconv.Itoa(int(getRedisPort(cache))) + `
+
+
+def get_redis_url(db):
+	query = '?' + urlencode(REDIS_SSL_OPTIONS) if REDIS_SSL_OPTIONS else ''
+	return '` + scheme + `://{}{}:{}/{}{}'.format(get_redis_credentials(), REDIS_HOST, REDIS_PORT, db, query)
+
+
+CACHE_CONFIG = {
+	'CACHE_TYPE': 'RedisCache',
+	'CACHE_DEFAULT_TIMEOUT': 300,
+	'CACHE_KEY_PREFIX': 'superset_',
+	'CACHE_REDIS_URL': get_redis_url(` + strconv.Itoa(int(cacheDB)) + `),
+}
+`
+}
+
+// getRedisSentinelConfig renders the cache of a redis discovered by sentinel.
+// The options prefixed with sentinel_ are passed by flask-caching to the connections to the sentinels.
+func (b *SupersetConfigMapBuilder) getRedisSentinelConfig(cache *supersetv1alpha1.CacheSpec) string {
+	sentinel := cache.Sentinel
+	cacheDB := getRedisDBs(cache).cache
+
+	sentinels := make([]string, 0, len(sentinel.Nodes))
+	for _, node := range sentinel.Nodes {
+		sentinels = append(sentinels, "("+strconv.Quote(node.Host)+", "+strconv.Itoa(int(getRedisSentinelPort(node)))+")")
+	}
+
+	return `
+REDIS_SENTINELS = [` + strings.Join(sentinels, ", ") + `]
+REDIS_SENTINEL_MASTER = ` + strconv.Quote(sentinel.MasterName) + `
+REDIS_SENTINEL_PASSWORD = os.environ.get('` + RedisSentinelPasswordEnv + `')
+# The connections are created by redis-py, it needs the ssl flag in addition to the options.
+REDIS_CONNECTION_SSL_OPTIONS = {'ssl': True, **REDIS_SSL_OPTIONS} if REDIS_SSL_OPTIONS else {}
+REDIS_SENTINEL_KWARGS = {'password': REDIS_SENTINEL_PASSWORD, **REDIS_CONNECTION_SSL_OPTIONS}
+
+
+def get_redis_url(db):
+	return ';'.join(
+		'sentinel://{}{}:{}/{}'.format(get_redis_credentials(), host, port, db) for host, port in REDIS_SENTINELS)
+
+
+CACHE_CONFIG = {
+	'CACHE_TYPE': 'RedisSentinelCache',
+	'CACHE_DEFAULT_TIMEOUT': 300,
+	'CACHE_KEY_PREFIX': 'superset_',
+	'CACHE_REDIS_SENTINELS': REDIS_SENTINELS,
+	'CACHE_REDIS_SENTINEL_MASTER': REDIS_SENTINEL_MASTER,
+	'CACHE_REDIS_SENTINEL_PASSWORD': REDIS_SENTINEL_PASSWORD,
+	'CACHE_REDIS_PASSWORD': REDIS_PASSWORD,
+	'CACHE_REDIS_DB': ` + strconv.Itoa(int(cacheDB)) + `,
+	'CACHE_OPTIONS': {
+		'username': REDIS_USERNAME,
+		**REDIS_CONNECTION_SSL_OPTIONS,
+		**{'sentinel_' + key: value for key, value in REDIS_CONNECTION_SSL_OPTIONS.items()},
+	},
+}
+`
+}
+
+// getCeleryBackendsConfig renders the broker and result backend of CeleryConfig,
+// redis if the cache is set, otherwise the metadata database.
+func (b *SupersetConfigMapBuilder) getCeleryBackendsConfig() string {
+	cache := b.ClusterConfig.Cache
+	if cache == nil {
+		return `    broker_url = 'sqla+' + SQLALCHEMY_DATABASE_URI if SQLALCHEMY_DATABASE_URI else None
+    result_backend = 'db+' + SQLALCHEMY_DATABASE_URI if SQLALCHEMY_DATABASE_URI else None
+` + b.getCeleryDatabaseTLSConfig()
+	}
+
+	dbs := getRedisDBs(cache)
+	config := `    broker_url = get_redis_url(` + strconv.Itoa(int(dbs.broker)) + `)
+    result_backend = get_redis_url(` + strconv.Itoa(int(dbs.resultBackend)) + `)
+`
+	if cache.Sentinel != nil {
+		config += `    broker_transport_options = {'master_name': REDIS_SENTINEL_MASTER, 'sentinel_kwargs': REDIS_SENTINEL_KWARGS}
+    result_backend_transport_options = {'master_name': REDIS_SENTINEL_MASTER, 'sentinel_kwargs': REDIS_SENTINEL_KWARGS}
+`
+	}
+	if cache.TLS != nil {
+		config += `    broker_use_ssl = REDIS_SSL_OPTIONS
+    redis_backend_use_ssl = REDIS_SSL_OPTIONS
+`
+	}
+	return config
+}
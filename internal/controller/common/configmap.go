@@ -2,8 +2,10 @@ package common
 
 import (
 	"context"
+	"maps"
 	"net/url"
 	"path"
+	"slices"
 	"strconv"
 	"strings"
 
@@ -14,7 +16,6 @@ import (
 	"github.com/zncdatadev/operator-go/pkg/productlogging"
 	"github.com/zncdatadev/operator-go/pkg/reconciler"
 	"github.com/zncdatadev/operator-go/pkg/util"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
 
 	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
@@ -23,6 +24,8 @@ import (
 const (
 	SupersetConfigFilename = "superset_config.py"
 	SupersetLogFilename    = "log_config.py"
+
+	SupersetSecurityManagerFilename = "security_manager.py"
 )
 
 var (
@@ -36,6 +39,18 @@ const (
 	DefaultLDAPFieldSurname   = "sn"
 	DefaultLDAPFieldUid       = "uid"
 
+	SyncRolesAtLogin            = "Login"
+	DefaultUserRegistrationRole = "Public"
+
+	LDAPBindCredentialsVolumeName = "ldap-bind-credentials"
+	LDAPTLSVolumeName             = "ldap-tls"
+	OIDCTLSVolumeName             = "oidc-tls"
+	DefaultLDAPSPort              = 636
+
+	// The keys of the client id and secret in the OIDC client credentials secret.
+	OIDCClientIDSecretKey     = "CLIENT_ID"
+	OIDCClientSecretSecretKey = "CLIENT_SECRET"
+
 	LDAPBindCredentialsUserFilename     = "user"
 	LDAPBindCredentialsPasswordFilename = "password"
 )
@@ -45,6 +60,9 @@ type SupersetConfigMapBuilder struct {
 
 	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
 
+	// BeatSchedules are rendered as celery beat_schedule, only the beat role sets them.
+	BeatSchedules map[string]supersetv1alpha1.BeatScheduleSpec
+
 	ClusterName   string
 	RoleName      string
 	RoleGroupName string
@@ -137,30 +155,43 @@ class JsonLoggingConfigurator(LoggingConfigurator):
 	return util.IndentTab4Spaces(config)
 }
 
-func (b *SupersetConfigMapBuilder) getAuthProvider(ctx context.Context) (*authv1alpha1.AuthenticationProvider, error) {
-	if b.ClusterConfig.Authentication == nil {
-		return nil, nil
-	}
-
-	authClass := &authv1alpha1.AuthenticationClass{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:      b.ClusterConfig.Authentication.AuthenticationClass,
-			Namespace: b.Client.GetOwnerNamespace(),
-		},
-	}
-	if err := b.Client.GetWithObject(ctx, authClass); err != nil {
-		return nil, err
-	}
+// getSecurityManagerConfig returns the security manager module,
+// superset_config.py only imports the security manager of the configured authentication.
+func (b *SupersetConfigMapBuilder) getSecurityManagerConfig() string {
+	config := `
+import base64
+import logging
 
-	return authClass.Spec.AuthenticationProvider, nil
+import requests
+from flask import Response, abort, current_app, g, redirect, request
+from flask_appbuilder.security.views import AuthRemoteUserView
+from flask_appbuilder.views import expose
+from flask_login import login_user, logout_user
+from superset.security import SupersetSecurityManager
 
+logger = logging.getLogger(__name__)
+` + getOAuthSecurityManagerConfig() + getRemoteUserSecurityManagerConfig() + getKerberosSecurityManagerConfig() +
+		getOpaSecurityManagerConfig()
+	return util.IndentTab4Spaces(config)
 }
 
-func (b *SupersetConfigMapBuilder) getLDAPConfig(ldapProvider authv1alpha1.LDAPProvider) string {
+func (b *SupersetConfigMapBuilder) getLDAPConfig(authentication *Authentication) string {
+	method := authentication.GetLDAP()
+	ldapProvider := *method.Provider.LDAP
+
+	tls := ldapProvider.TLS != nil && ldapProvider.TLS.Verification != nil
+	startTLS := tls && method.Spec.Ldap != nil && method.Spec.Ldap.StartTLS
 
 	server := url.URL{Scheme: "ldap", Host: ldapProvider.Hostname}
-	if ldapProvider.Port != 0 {
-		server.Host += ":" + strconv.Itoa(ldapProvider.Port)
+	port := ldapProvider.Port
+	if tls && !startTLS {
+		server.Scheme = "ldaps"
+		if port == 0 {
+			port = DefaultLDAPSPort
+		}
+	}
+	if port != 0 {
+		server.Host += ":" + strconv.Itoa(port)
 	}
 
 	ldapFieldUid := DefaultLDAPFieldUid
@@ -177,14 +208,10 @@ func (b *SupersetConfigMapBuilder) getLDAPConfig(ldapProvider authv1alpha1.LDAPP
 		ldapFieldGroup = ldapProvider.LDAPFieldNames.Group
 	}
 
-	// AUTH_ROLES_MAPPING is a dictionary that maps LDAP groups to Superset roles for ldap permissions.
-	// The key is the LDAP group and the value is the Superset role.
-	// the LDAP group should be created in the LDAP server first, and add the user to the group.
 	config := `
-# Set the authentication type to OAuth
+# Set the authentication type to LDAP
 AUTH_TYPE = AUTH_LDAP
-AUTH_USER_REGISTRATION=True
-AUTH_LDAP_SERVER = '` + server.String() + `'
+` + b.getUserRegistrationConfig(authentication) + `AUTH_LDAP_SERVER = '` + server.String() + `'
 AUTH_LDAP_SEARCH = '` + ldapProvider.SearchBase + `'
 AUTH_LDAP_SEARCH_FILTER = '` + ldapProvider.SearchFilter + `'
 AUTH_LDAP_UID_FIELD = '` + ldapFieldUid + `'
@@ -192,11 +219,7 @@ AUTH_LDAP_GROUP_FIELD = '` + ldapFieldGroup + `'
 AUTH_LDAP_FIRSTNAME_FIELD = '` + ldapFieldGivenName + `'
 AUTH_LDAP_LASTNAME_FIELD = '` + ldapFieldSurname + `'
 AUTH_LDAP_EMAIL_FIELD = '` + ldapFieldEmail + `'
-AUTH_ROLES_MAPPING = {
-	"cn=superset_users,ou=groups,dc=example,dc=com": ["Admin"],
-	"cn=superset_admins,ou=groups,dc=example,dc=com": ["Admin"],
-}
-`
+` + b.getRoleMappingConfig(authentication)
 
 	if ldapProvider.BindCredentials != nil {
 		mouhtPath := path.Join(constants.KubedoopSecretDir, ldapProvider.BindCredentials.SecretClass)
@@ -209,58 +232,271 @@ with open('` + path.Join(mouhtPath, LDAPBindCredentialsPasswordFilename) + `', '
 `
 	}
 
-	// TODO: Add TLS configuration
+	if tls {
+		config += b.getLDAPTLSConfig(ldapProvider, startTLS)
+	}
+
 	return util.IndentTab4Spaces(config)
 }
 
-func (b *SupersetConfigMapBuilder) getOIDCConfig(oidcPrivider authv1alpha1.OIDCProvider) string {
-	scopes := []string{"openid", "email", "profile"}
-	issuer := url.URL{
-		Scheme: "http",
-		Host:   oidcPrivider.Hostname,
-		Path:   oidcPrivider.RootPath,
+// GetLDAPCASecretClass returns the secret class providing the CA of the LDAP server,
+// empty if TLS is disabled, not verified or verified with the web PKI.
+func GetLDAPCASecretClass(ldapProvider *authv1alpha1.LDAPProvider) string {
+	if ldapProvider.TLS == nil || ldapProvider.TLS.Verification == nil {
+		return ""
+	}
+	server := ldapProvider.TLS.Verification.Server
+	if server == nil || server.CACert == nil {
+		return ""
+	}
+	return server.CACert.SecretClass
+}
+
+// getLDAPTLSConfig renders the TLS settings of the LDAP connection.
+// Without server verification self signed certificates are allowed,
+// otherwise the certificate is verified with the CA of the secret class or the system CAs for the web PKI.
+func (b *SupersetConfigMapBuilder) getLDAPTLSConfig(ldapProvider authv1alpha1.LDAPProvider, startTLS bool) string {
+	config := `
+AUTH_LDAP_USE_TLS = ` + pythonBool(startTLS) + `
+`
+	if ldapProvider.TLS.Verification.Server == nil {
+		config += `AUTH_LDAP_ALLOW_SELF_SIGNED = True
+AUTH_LDAP_TLS_DEMAND = False
+`
+		return config
+	}
+
+	config += `AUTH_LDAP_ALLOW_SELF_SIGNED = False
+AUTH_LDAP_TLS_DEMAND = True
+`
+	if caSecretClass := GetLDAPCASecretClass(&ldapProvider); caSecretClass != "" {
+		config += `AUTH_LDAP_TLS_CACERTFILE = '` + path.Join(constants.KubedoopSecretDir, caSecretClass, "ca.crt") + `'
+`
 	}
+	return config
+}
+
+// getUserRegistrationConfig renders whether and with which role the authenticated users are registered.
+// The settings are the same for every authentication class.
+func (b *SupersetConfigMapBuilder) getUserRegistrationConfig(authentication *Authentication) string {
+	settings := authentication.GetSettings()
+	return renderUserRegistrationConfig(settings.UserRegistration, settings.UserRegistrationRole)
+}
 
-	if oidcPrivider.Port != 0 {
-		issuer.Host += ":" + strconv.Itoa(oidcPrivider.Port)
+func renderUserRegistrationConfig(userRegistrationEnabled *bool, role string) string {
+	userRegistration := userRegistrationEnabled == nil || *userRegistrationEnabled
+	userRegistrationRole := DefaultUserRegistrationRole
+	if role != "" {
+		userRegistrationRole = role
 	}
 
-	if b.ClusterConfig.Authentication.Oidc != nil {
-		scopes = append(scopes, b.ClusterConfig.Authentication.Oidc.ExtraScopes...)
+	return `AUTH_USER_REGISTRATION = ` + pythonBool(userRegistration) + `
+AUTH_USER_REGISTRATION_ROLE = ` + strconv.Quote(userRegistrationRole) + `
+`
+}
+
+// getRoleMappingConfig renders AUTH_ROLES_MAPPING, it maps the LDAP groups or OAuth role keys to superset roles,
+// and AUTH_ROLES_SYNC_AT_LOGIN. The role mappings of all authentication classes are merged.
+func (b *SupersetConfigMapBuilder) getRoleMappingConfig(authentication *Authentication) string {
+	roleMapping := authentication.GetRoleMapping()
+
+	config := `
+AUTH_ROLES_MAPPING = {
+`
+	for _, group := range slices.Sorted(maps.Keys(roleMapping)) {
+		roles := make([]string, 0, len(roleMapping[group]))
+		for _, role := range roleMapping[group] {
+			roles = append(roles, strconv.Quote(role))
+		}
+		config += `	` + strconv.Quote(group) + `: [` + strings.Join(roles, ", ") + `],
+`
 	}
+	config += `}
+`
 
-	providerHint := oidcPrivider.ProviderHint
+	config += `AUTH_ROLES_SYNC_AT_LOGIN = ` + pythonBool(authentication.GetSettings().SyncRolesAt == SyncRolesAtLogin) + `
+`
+	return config
+}
+
+func pythonBool(b bool) string {
+	if b {
+		return "True"
+	}
+	return "False"
+}
 
+// getOIDCConfig renders an OAuth provider for every OIDC authentication class, named after the class.
+// The login page offers a button per provider.
+func (b *SupersetConfigMapBuilder) getOIDCConfig(authentication *Authentication) string {
 	config := `
 # Set the authentication type to OAuth
 AUTH_TYPE = AUTH_OAUTH
+`
+	config += b.getUserRegistrationConfig(authentication)
+	config += b.getRoleMappingConfig(authentication)
+	config += `
+# The providers are named after their authentication class, so the user info is mapped by the custom security manager.
+from security_manager import OAuthSecurityManager
+
+CUSTOM_SECURITY_MANAGER = OAuthSecurityManager
 
-AUTH_ROLES_SYNC_AT_LOGIN = False
-AUTH_TYPE = AUTH_OAUTH
-AUTH_USER_REGISTRATION = True
-AUTH_USER_REGISTRATION_ROLE = "Public"
 OAUTH_PROVIDERS = [
-    {   'name': '` + providerHint + `',    # Name of the provider
-        'token_key': 'access_token',    # Name of the token in the response of access_token_url
-        'icon': 'fa-address-card',    # Icon for the provider
-        'remote_app': {
-            'client_id': os.environ.get('CLIENT_ID'),    # Client Id (Identify Superset application)
-            'client_secret': os.environ.get('CLIENT_SECRET'),    # Secret for this Client Id (Identify Superset application)
-            'client_kwargs': {
-                'scope': '` + strings.Join(scopes, " ") + `'               # Scope for the Authorization
-            },
-			'api_base_url': '` + issuer.String() + `/protocol/',    # Base URL for the API
-            'server_metadata_url': '` + issuer.String() + `/.well-known/openid-configuration',
-        }
-    }
-]
+`
+	for _, method := range authentication.GetOIDC() {
+		config += getOAuthProviderConfig(method)
+	}
+	config += `]
 `
 	return util.IndentTab4Spaces(config)
 }
 
-func (b *SupersetConfigMapBuilder) getAPPConfig(authProvider *authv1alpha1.AuthenticationProvider) string {
-	config := `import os
+// GetOIDCCASecretClass returns the secret class providing the CA of the OIDC provider,
+// empty if TLS is disabled, not verified or verified with the web PKI.
+func GetOIDCCASecretClass(oidcProvider *authv1alpha1.OIDCProvider) string {
+	if oidcProvider.TLS == nil || oidcProvider.TLS.Verification == nil {
+		return ""
+	}
+	server := oidcProvider.TLS.Verification.Server
+	if server == nil || server.CACert == nil {
+		return ""
+	}
+	return server.CACert.SecretClass
+}
+
+// getOIDCVerifyConfig renders the requests session verify option of the authlib client,
+// it is used for the server metadata, token and user info requests.
+// The web PKI is verified with the default CA bundle, so nothing is rendered for it.
+func getOIDCVerifyConfig(oidcProvider authv1alpha1.OIDCProvider) string {
+	if oidcProvider.TLS == nil || oidcProvider.TLS.Verification == nil {
+		return ""
+	}
+	if oidcProvider.TLS.Verification.Server == nil {
+		return `                'verify': False,
+`
+	}
+	if caSecretClass := GetOIDCCASecretClass(&oidcProvider); caSecretClass != "" {
+		return `                'verify': '` + path.Join(constants.KubedoopSecretDir, caSecretClass, "ca.crt") + `',
+`
+	}
+	return ""
+}
+
+// getDatabaseConfig renders the SQLAlchemy URI, built from the metadata database if set,
+// otherwise read from the credentials secret, and the options of its engine.
+func (b *SupersetConfigMapBuilder) getDatabaseConfig() string {
+	database := b.ClusterConfig.MetadataDatabase
+
+	config := `SQLALCHEMY_DATABASE_URI = os.environ.get('SQLALCHEMY_DATABASE_URI')
+`
+	if database != nil {
+		config = b.getMetadataDatabaseConfig(database)
+	}
+	config += b.getDatabaseConnectionPoolConfig(b.ClusterConfig.DatabaseConnectionPool)
+	if database != nil && database.TLS != nil {
+		config += b.getMetadataDatabaseTLSConfig(database)
+	}
+	return config
+}
+
+// getCeleryConfig returns the celery config shared by the node and worker roles.
+// Redis, or else the metadata database, is used as broker and result backend, it replaces
+// the sqlite default of superset which can not be shared between pods.
+func (b *SupersetConfigMapBuilder) getCeleryConfig() string {
+	return `
+class CeleryConfig:
+` + b.getCeleryBackendsConfig() + `    imports = (
+        'superset.sql_lab',
+        'superset.tasks.scheduler',
+        'superset.tasks.thumbnails',
+        'superset.tasks.cache',
+    )
+    worker_prefetch_multiplier = 1
+    task_acks_late = False
+` + b.getBeatScheduleConfig() + `
+
+CELERY_CONFIG = CeleryConfig
+` + getCeleryWorkerHeartbeatConfig()
+}
+
+// getCeleryWorkerHeartbeatConfig renders a bootstep of the celery worker touching the heartbeat file
+// checked by the liveness probe. It is added to the worker when it is initialized, so it works with every broker,
+// and it stops once the timer of the worker is blocked.
+func getCeleryWorkerHeartbeatConfig() string {
+	return `
+
+from pathlib import Path
+
+from celery import bootsteps
+from celery.signals import celeryd_init
+
+
+class WorkerHeartbeat(bootsteps.StartStopStep):
+    requires = {'celery.worker.components:Timer'}
+
+    def __init__(self, worker, **kwargs):
+        super().__init__(worker, **kwargs)
+        self.heartbeat_file = Path('` + WorkerHeartbeatFile + `')
+        self.tref = None
+
+    def start(self, worker):
+        self.heartbeat_file.touch()
+        self.tref = worker.timer.call_repeatedly(5.0, self.heartbeat_file.touch, priority=10)
+
+    def stop(self, worker):
+        if self.tref is not None:
+            self.tref.cancel()
+        self.heartbeat_file.unlink(missing_ok=True)
+
+
+@celeryd_init.connect
+def add_worker_heartbeat(sender=None, instance=None, **kwargs):
+    instance.app.steps['worker'].add(WorkerHeartbeat)
+`
+}
+
+// getBeatScheduleConfig renders the beat schedules as attribute of CeleryConfig.
+func (b *SupersetConfigMapBuilder) getBeatScheduleConfig() string {
+	if len(b.BeatSchedules) == 0 {
+		return ""
+	}
+
+	names := slices.Sorted(maps.Keys(b.BeatSchedules))
+
+	config := `
+    beat_schedule = {
+`
+	for _, name := range names {
+		schedule := b.BeatSchedules[name]
+		// Crontab is validated by the CRD, it always has five fields.
+		fields := strings.Fields(schedule.Crontab)
+		config += `        ` + strconv.Quote(name) + `: {
+            'task': ` + strconv.Quote(schedule.Task) + `,
+            'schedule': crontab(
+                minute=` + strconv.Quote(fields[0]) + `,
+                hour=` + strconv.Quote(fields[1]) + `,
+                day_of_month=` + strconv.Quote(fields[2]) + `,
+                month_of_year=` + strconv.Quote(fields[3]) + `,
+                day_of_week=` + strconv.Quote(fields[4]) + `,
+            ),
+`
+		if schedule.Kwargs != nil && len(schedule.Kwargs.Raw) > 0 {
+			config += `            'kwargs': json.loads(` + strconv.Quote(string(schedule.Kwargs.Raw)) + `),
+`
+		}
+		config += `        },
+`
+	}
+	config += `    }
+`
+	return config
+}
+
+func (b *SupersetConfigMapBuilder) getAPPConfig(authentication *Authentication) string {
+	config := `import json
+import os
 
+from celery.schedules import crontab
 from flask_appbuilder.security.manager import ( AUTH_DB, AUTH_LDAP, AUTH_OAUTH, AUTH_OID, AUTH_REMOTE_USER )
 from superset.stats_logger import StatsdStatsLogger
 
@@ -271,26 +507,57 @@ LOGGING_CONFIGURATOR = JsonLoggingConfigurator()
 
 ROW_LIMIT = 10000
 
+FEATURE_FLAGS = {}
+
 SECRET_KEY = os.environ.get('SECRET_KEY')
 
-SQLALCHEMY_DATABASE_URI = os.environ.get('SQLALCHEMY_DATABASE_URI')
+# Only set in the re-encrypt job after a rotation, 'superset re-encrypt-secrets' decrypts the stored secrets with it.
+if os.environ.get('PREVIOUS_SECRET_KEY'):
+	PREVIOUS_SECRET_KEY = os.environ.get('PREVIOUS_SECRET_KEY')
 
+` + b.getDatabaseConfig() + `
 STATS_LOGGER = StatsdStatsLogger(host='0.0.0.0', port=9125)
 
 SUPERSET_WEBSERVER_TIMEOUT = 300
 
 TALISMAN_ENABLED = False
 `
-	if authProvider == nil {
-		return util.IndentTab4Spaces(config)
+	if b.ClusterConfig.Cache != nil {
+		config += b.getCacheConfig(b.ClusterConfig.Cache)
+	}
+	if b.ClusterConfig.Session != nil {
+		config += b.getSessionConfig(b.ClusterConfig.Session)
+	}
+	if b.ClusterConfig.ResultsBackend != nil {
+		config += b.getResultsBackendConfig(b.ClusterConfig.ResultsBackend)
+	}
+	if b.ClusterConfig.AlertsAndReports != nil {
+		config += b.getAlertsAndReportsConfig(b.ClusterConfig.AlertsAndReports)
 	}
+	config += b.getCeleryConfig()
+
+	if b.ClusterConfig.RemoteUserAuthentication != nil {
+		config += b.getRemoteUserConfig(b.ClusterConfig.RemoteUserAuthentication)
+	}
+
+	if authentication != nil {
+		// The AuthenticationReconciler rejects combinations of LDAP, OIDC and Kerberos, so only one of them is rendered.
+		if len(authentication.GetOIDC()) > 0 {
+			config += b.getOIDCConfig(authentication)
+		}
+
+		if authentication.GetLDAP() != nil {
+			config += b.getLDAPConfig(authentication)
+		}
 
-	if authProvider.OIDC != nil {
-		config += b.getOIDCConfig(*authProvider.OIDC)
+		if authentication.GetKerberos() != nil {
+			config += b.getKerberosConfig(authentication)
+		}
 	}
 
-	if authProvider.LDAP != nil {
-		config += b.getLDAPConfig(*authProvider.LDAP)
+	// It wraps the security manager of the authentication, so it is rendered last.
+	if b.ClusterConfig.Authorization != nil && b.ClusterConfig.Authorization.Opa != nil {
+		config += b.getOpaConfig(b.ClusterConfig.Authorization.Opa)
 	}
 
 	return util.IndentTab4Spaces(config)
@@ -298,13 +565,22 @@ TALISMAN_ENABLED = False
 
 func (b *SupersetConfigMapBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
 
-	authProvider, err := b.getAuthProvider(ctx)
+	authentication, err := ResolveAuthentication(ctx, b.Client, b.ClusterConfig)
 	if err != nil {
 		return nil, err
 	}
 
 	b.AddItem(SupersetLogFilename, b.getLogConfig())
-	b.AddItem(SupersetConfigFilename, b.getAPPConfig(authProvider))
+	b.AddItem(SupersetSecurityManagerFilename, b.getSecurityManagerConfig())
+	b.AddItem(SupersetConfigFilename, b.getAPPConfig(authentication))
+	if b.ClusterConfig.ResultsBackend != nil {
+		b.AddItem(SupersetResultsBackendFilename, b.getResultsBackendModuleConfig())
+	}
+	if authentication != nil {
+		if kerberos := authentication.GetKerberos(); kerberos != nil {
+			b.AddItem(Krb5ConfigFilename, getKrb5Config(kerberos.Spec.Kerberos))
+		}
+	}
 
 	vectorConfig, err := b.getVectorConfig(ctx)
 	if err != nil {
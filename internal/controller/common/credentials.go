@@ -4,6 +4,8 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	"github.com/zncdatadev/operator-go/pkg/builder"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
 )
 
 var (
@@ -13,21 +15,25 @@ var (
 		{"ADMIN_LASTNAME", "adminUser.lastname"},
 		{"ADMIN_EMAIL", "adminUser.email"},
 		{"ADMIN_PASSWORD", "adminUser.password"},
-		{"SECRET_KEY", "appSecretKey"},
 		{"SQLALCHEMY_DATABASE_URI", "connections.sqlalchemyDatabaseUri"},
 	}
 )
 
-func InjectCredentials(credentialsSecret string, builder builder.ContainerBuilder) {
+// InjectCredentials adds the env vars of the credentials secret. The database URI is skipped
+// if the metadata database is set, the URI is built from it in superset_config.py instead.
+func InjectCredentials(clusterConfig *supersetv1alpha1.ClusterConfigSpec, builder builder.ContainerBuilder) {
 	envvars := make([]corev1.EnvVar, 0, len(credentialsKeyMapping))
 	for _, pair := range credentialsKeyMapping {
+		if pair[0] == "SQLALCHEMY_DATABASE_URI" && clusterConfig.MetadataDatabase != nil {
+			continue
+		}
 		envvars = append(envvars, corev1.EnvVar{
 			Name: pair[0],
 			ValueFrom: &corev1.EnvVarSource{
 				SecretKeyRef: &corev1.SecretKeySelector{
 					Key: pair[1],
 					LocalObjectReference: corev1.LocalObjectReference{
-						Name: credentialsSecret,
+						Name: clusterConfig.CredentialsSecret,
 					},
 				},
 			},
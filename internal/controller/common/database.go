@@ -0,0 +1,214 @@
+package common
+
+import (
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	MetadataDatabaseTypePostgresql = "postgresql"
+	MetadataDatabaseTypeMysql      = "mysql"
+
+	// The keys of the metadata database credentials secret.
+	MetadataDatabaseUsernameSecretKey = "username"
+	MetadataDatabasePasswordSecretKey = "password"
+
+	MetadataDatabaseUsernameEnv = "METADATA_DATABASE_USERNAME"
+	MetadataDatabasePasswordEnv = "METADATA_DATABASE_PASSWORD"
+
+	MetadataDatabaseTLSVolumeName = "metadata-database-tls"
+
+	// DefaultMetadataDatabaseTLSSSLMode is the ssl mode if the CA of the database is set.
+	DefaultMetadataDatabaseTLSSSLMode = "verify-full"
+
+	DefaultDatabasePoolRecycleSeconds = 3600
+)
+
+// mysqlSSLModes maps the postgresql sslmode to the ssl_mode of mysqlclient.
+var mysqlSSLModes = map[string]string{
+	"disable":     "DISABLED",
+	"allow":       "PREFERRED",
+	"prefer":      "PREFERRED",
+	"require":     "REQUIRED",
+	"verify-ca":   "VERIFY_CA",
+	"verify-full": "VERIFY_IDENTITY",
+}
+
+// InjectMetadataDatabase adds the env vars of the metadata database credentials.
+func InjectMetadataDatabase(database *supersetv1alpha1.MetadataDatabaseSpec, builder builder.ContainerBuilder) {
+	secretKeyRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key:                  key,
+				LocalObjectReference: corev1.LocalObjectReference{Name: database.CredentialsSecret},
+			},
+		}
+	}
+	builder.AddEnvVars([]corev1.EnvVar{
+		{Name: MetadataDatabaseUsernameEnv, ValueFrom: secretKeyRef(MetadataDatabaseUsernameSecretKey)},
+		{Name: MetadataDatabasePasswordEnv, ValueFrom: secretKeyRef(MetadataDatabasePasswordSecretKey)},
+	})
+}
+
+// NewMetadataDatabaseTLSVolume returns the volume of the CA of the metadata database, nil if TLS is disabled.
+// Every container connecting to the database mounts it, including the init job.
+func NewMetadataDatabaseTLSVolume(database *supersetv1alpha1.MetadataDatabaseSpec) (*corev1.Volume, *corev1.VolumeMount) {
+	if database == nil || database.TLS == nil {
+		return nil, nil
+	}
+	return NewSecretVolume(MetadataDatabaseTLSVolumeName, database.TLS.CASecretClass, nil, nil)
+}
+
+// addMetadataDatabaseTLS mounts the CA of the metadata database.
+func (b *StatefulSetBuilder) addMetadataDatabaseTLS(container builder.ContainerBuilder, database *supersetv1alpha1.MetadataDatabaseSpec) {
+	b.addSecretVolume(container, MetadataDatabaseTLSVolumeName, database.TLS.CASecretClass, nil)
+}
+
+// getMetadataDatabaseSSLMode returns the ssl mode of the spec, verify-full if only the CA is set.
+func getMetadataDatabaseSSLMode(database *supersetv1alpha1.MetadataDatabaseSpec) string {
+	if database.SSLMode == "" && database.TLS != nil {
+		return DefaultMetadataDatabaseTLSSSLMode
+	}
+	return database.SSLMode
+}
+
+// getMetadataDatabasePort returns the port of the metadata database, the default port of its type if not set.
+func getMetadataDatabasePort(database *supersetv1alpha1.MetadataDatabaseSpec) int32 {
+	if database.Port != 0 {
+		return database.Port
+	}
+	if database.Type == MetadataDatabaseTypeMysql {
+		return 3306
+	}
+	return 5432
+}
+
+// getMetadataDatabaseQuery returns the query parameters of the URI for the ssl mode of the spec.
+func getMetadataDatabaseQuery(database *supersetv1alpha1.MetadataDatabaseSpec) map[string]string {
+	sslMode := getMetadataDatabaseSSLMode(database)
+	if sslMode == "" {
+		return nil
+	}
+	if database.Type == MetadataDatabaseTypeMysql {
+		return map[string]string{"ssl_mode": mysqlSSLModes[sslMode]}
+	}
+	return map[string]string{"sslmode": sslMode}
+}
+
+// pythonStringDict renders the strings as python dict, sorted by key.
+func pythonStringDict(values map[string]string) string {
+	config := "{"
+	for i, key := range slices.Sorted(maps.Keys(values)) {
+		if i > 0 {
+			config += ", "
+		}
+		config += strconv.Quote(key) + ": " + strconv.Quote(values[key])
+	}
+	return config + "}"
+}
+
+// getMetadataDatabaseConfig renders the SQLAlchemy URI of the metadata database.
+// The URL is created by SQLAlchemy, so the credentials are escaped whatever characters they contain.
+func (b *SupersetConfigMapBuilder) getMetadataDatabaseConfig(database *supersetv1alpha1.MetadataDatabaseSpec) string {
+	drivername := "postgresql+psycopg2"
+	if database.Type == MetadataDatabaseTypeMysql {
+		drivername = "mysql+mysqldb"
+	}
+
+	return `from sqlalchemy.engine import URL
+
+SQLALCHEMY_DATABASE_URI = URL.create(
+	'` + drivername + `',
+	username=os.environ.get('` + MetadataDatabaseUsernameEnv + `'),
+	password=os.environ.get('` + MetadataDatabasePasswordEnv + `'),
+	host=` + strconv.Quote(database.Host) + `,
+	port=` + strconv.Itoa(int(getMetadataDatabasePort(database))) + `,
+	database=` + strconv.Quote(database.Database) + `,
+	query=` + pythonStringDict(getMetadataDatabaseQuery(database)) + `,
+).render_as_string(hide_password=False)
+`
+}
+
+// getMetadataDatabaseTLSConfig renders the CA of the metadata database as connect args of the engine,
+// they are passed to the engines of the celery broker and result backend as well.
+func (b *SupersetConfigMapBuilder) getMetadataDatabaseTLSConfig(database *supersetv1alpha1.MetadataDatabaseSpec) string {
+	if database.TLS == nil {
+		return ""
+	}
+
+	caPath := strconv.Quote(path.Join(constants.KubedoopSecretDir, database.TLS.CASecretClass, "ca.crt"))
+	sslMode := getMetadataDatabaseSSLMode(database)
+
+	connectArgs := `{'sslmode': ` + strconv.Quote(sslMode) + `, 'sslrootcert': ` + caPath + `}`
+	if database.Type == MetadataDatabaseTypeMysql {
+		connectArgs = `{'ssl_mode': ` + strconv.Quote(mysqlSSLModes[sslMode]) + `, 'ssl': {'ca': ` + caPath + `}}`
+	}
+
+	return `SQLALCHEMY_ENGINE_OPTIONS['connect_args'] = ` + connectArgs + `
+`
+}
+
+// getCeleryDatabaseTLSConfig passes the engine options of the metadata database to the broker
+// and result backend, they create their own engines from the URI.
+func (b *SupersetConfigMapBuilder) getCeleryDatabaseTLSConfig() string {
+	if b.ClusterConfig.MetadataDatabase == nil || b.ClusterConfig.MetadataDatabase.TLS == nil {
+		return ""
+	}
+	return `    broker_transport_options = SQLALCHEMY_ENGINE_OPTIONS
+    database_engine_options = SQLALCHEMY_ENGINE_OPTIONS
+`
+}
+
+// getDatabaseThreads returns the threads of a superset process of the role, which use a connection at the same time.
+// The web server of a node replica runs the gunicorn threads, the beat and every pool process of a worker
+// run one task at a time, the worker concurrency only adds processes with their own pool.
+func (b *SupersetConfigMapBuilder) getDatabaseThreads() int32 {
+	if b.RoleName == NodeRoleName {
+		return GunicornThreads
+	}
+	return 1
+}
+
+// getDatabaseConnectionPoolConfig renders the connection pool as engine options, the SQLALCHEMY_POOL_*
+// settings are deprecated by flask-sqlalchemy and pre ping has no setting at all.
+// The defaults are derived from the threads of the process: a quarter of them is kept in the pool,
+// and the overflow covers the remaining threads, so no thread waits for a connection
+// while the idle connections of the cluster stay low.
+func (b *SupersetConfigMapBuilder) getDatabaseConnectionPoolConfig(pool *supersetv1alpha1.DatabaseConnectionPoolSpec) string {
+	threads := b.getDatabaseThreads()
+	poolSize := max(threads/4, 1)
+	poolRecycle := int32(DefaultDatabasePoolRecycleSeconds)
+	prePing := true
+	if pool != nil && pool.PoolSize != nil {
+		poolSize = *pool.PoolSize
+	}
+	maxOverflow := max(threads-poolSize, 1)
+	if pool != nil {
+		if pool.MaxOverflow != nil {
+			maxOverflow = *pool.MaxOverflow
+		}
+		if pool.PoolRecycleSeconds != nil {
+			poolRecycle = *pool.PoolRecycleSeconds
+		}
+		if pool.PoolPrePing != nil {
+			prePing = *pool.PoolPrePing
+		}
+	}
+
+	return `
+SQLALCHEMY_ENGINE_OPTIONS = {
+	'pool_size': ` + strconv.Itoa(int(poolSize)) + `,
+	'max_overflow': ` + strconv.Itoa(int(maxOverflow)) + `,
+	'pool_recycle': ` + strconv.Itoa(int(poolRecycle)) + `,
+	'pool_pre_ping': ` + pythonBool(prePing) + `,
+}
+`
+}
@@ -0,0 +1,158 @@
+package common
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	// WaitForDependenciesContainerName is the init container waiting for the services superset depends on,
+	// the status reports the role groups whose pods are stuck in it.
+	WaitForDependenciesContainerName = "wait-for-dependencies"
+
+	DefaultDependencyTimeout = 5 * time.Minute
+)
+
+// dependency is a service superset connects to at startup.
+type dependency struct {
+	name string
+	// addresses of the service, it is reachable if any of them is, e.g. one of the redis sentinels.
+	addresses []address
+	// uriEnv is the env var of a SQLAlchemy URI the address is read from, if it is not known.
+	uriEnv string
+}
+
+type address struct {
+	host string
+	port int32
+}
+
+// getDependencies returns the services the pods wait for.
+func getDependencies(clusterConfig *supersetv1alpha1.ClusterConfigSpec) []dependency {
+	dependencies := []dependency{{name: "metadata database", uriEnv: "SQLALCHEMY_DATABASE_URI"}}
+	if database := clusterConfig.MetadataDatabase; database != nil {
+		dependencies = []dependency{{
+			name:      "metadata database",
+			addresses: []address{{host: database.Host, port: getMetadataDatabasePort(database)}},
+		}}
+	}
+
+	if cache := clusterConfig.Cache; cache != nil {
+		if cache.Sentinel != nil {
+			sentinels := dependency{name: "redis sentinel"}
+			for _, node := range cache.Sentinel.Nodes {
+				sentinels.addresses = append(sentinels.addresses, address{host: node.Host, port: getRedisSentinelPort(node)})
+			}
+			dependencies = append(dependencies, sentinels)
+		} else {
+			dependencies = append(dependencies, dependency{
+				name:      "redis",
+				addresses: []address{{host: cache.Host, port: getRedisPort(cache)}},
+			})
+		}
+	}
+	return dependencies
+}
+
+// getDependencyTimeout returns the time the pods wait for the dependencies.
+func getDependencyTimeout(clusterConfig *supersetv1alpha1.ClusterConfigSpec) time.Duration {
+	if clusterConfig.DependencyTimeout != nil {
+		return clusterConfig.DependencyTimeout.Duration
+	}
+	return DefaultDependencyTimeout
+}
+
+// getWaitForDependenciesCommands renders the script connecting to every dependency until it is reachable.
+// It fails once the timeout expires, so the container is restarted with backoff and the pod does not hang silently.
+func getWaitForDependenciesCommands(clusterConfig *supersetv1alpha1.ClusterConfigSpec) string {
+	dependencies := ""
+	for _, dep := range getDependencies(clusterConfig) {
+		if dep.uriEnv != "" {
+			dependencies += `	(` + strconv.Quote(dep.name) + `, [], ` + strconv.Quote(dep.uriEnv) + `),
+`
+			continue
+		}
+		addresses := make([]string, 0, len(dep.addresses))
+		for _, addr := range dep.addresses {
+			addresses = append(addresses, `(`+strconv.Quote(addr.host)+`, `+strconv.Itoa(int(addr.port))+`)`)
+		}
+		dependencies += `	(` + strconv.Quote(dep.name) + `, [` + strings.Join(addresses, ", ") + `], None),
+`
+	}
+
+	cmds := `
+python3 - <<'EOF'
+import os
+import socket
+import sys
+import time
+
+from sqlalchemy.engine import make_url
+
+DEFAULT_PORTS = {'postgresql': 5432, 'mysql': 3306}
+DEPENDENCIES = [
+` + dependencies + `]
+
+deadline = time.monotonic() + ` + strconv.Itoa(int(getDependencyTimeout(clusterConfig).Seconds())) + `
+
+def is_reachable(host, port):
+	try:
+		socket.create_connection((host, port), timeout=5).close()
+		return True
+	except OSError as e:
+		print('{}:{} is not reachable: {}'.format(host, port, e))
+		return False
+
+
+for name, addresses, uri_env in DEPENDENCIES:
+	if uri_env:
+		url = make_url(os.environ[uri_env]) if os.environ.get(uri_env) else None
+		if url is None or not url.host:
+			print('Skipping {}, it has no host'.format(name))
+			continue
+		addresses = [(url.host, url.port or DEFAULT_PORTS.get(url.get_backend_name()))]
+
+	while not any(is_reachable(host, port) for host, port in addresses):
+		if time.monotonic() > deadline:
+			print('Timed out waiting for {}'.format(name), file=sys.stderr)
+			sys.exit(1)
+		print('Waiting for {}'.format(name))
+		time.sleep(2)
+	print('{} is reachable'.format(name))
+EOF
+`
+	return util.IndentTab4Spaces(cmds)
+}
+
+// NewWaitForDependenciesContainer returns the init container waiting for the dependencies,
+// it runs the superset image as its python has the SQLAlchemy URI parser.
+func NewWaitForDependenciesContainer(
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+) builder.ContainerBuilder {
+	containerBuilder := builder.NewContainer(WaitForDependenciesContainerName, image)
+	containerBuilder.SetCommand([]string{"sh", "-c"})
+	containerBuilder.SetArgs([]string{getWaitForDependenciesCommands(clusterConfig)})
+
+	if clusterConfig.MetadataDatabase == nil && clusterConfig.CredentialsSecret != "" {
+		containerBuilder.AddEnvVars([]corev1.EnvVar{
+			{
+				Name: "SQLALCHEMY_DATABASE_URI",
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						Key:                  "connections.sqlalchemyDatabaseUri",
+						LocalObjectReference: corev1.LocalObjectReference{Name: clusterConfig.CredentialsSecret},
+					},
+				},
+			},
+		})
+	}
+	return containerBuilder
+}
@@ -0,0 +1,123 @@
+package common
+
+import (
+	"path"
+	"strings"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	Krb5ConfigFilename = "krb5.conf"
+	KerberosVolumeName = "kerberos"
+
+	// KerberosServiceName is the service of the principal of the web server, browsers request
+	// a ticket for HTTP/<host>@<realm>.
+	KerberosServiceName = "HTTP"
+
+	KerberosKeytabFilename = "keytab"
+)
+
+// getKrb5Config renders the krb5.conf of the realm, it is mounted with the superset config.
+func getKrb5Config(kerberos *supersetv1alpha1.KerberosSpec) string {
+	adminServer := kerberos.AdminServer
+	if adminServer == "" {
+		adminServer = strings.Split(kerberos.Kdc, ":")[0]
+	}
+
+	return `[libdefaults]
+    default_realm = ` + kerberos.Realm + `
+    dns_lookup_realm = false
+    dns_lookup_kdc = false
+    rdns = false
+    udp_preference_limit = 1
+
+[realms]
+    ` + kerberos.Realm + ` = {
+        kdc = ` + kerberos.Kdc + `
+        admin_server = ` + adminServer + `
+    }
+`
+}
+
+// getKerberosConfig renders the Kerberos authentication. flask-appbuilder has no SPNEGO support,
+// so the remote user authentication is used, with a login view negotiating the ticket of the user.
+func (b *SupersetConfigMapBuilder) getKerberosConfig(authentication *Authentication) string {
+	return `
+# Set the authentication type to remote user, the user is authenticated with SPNEGO by the custom security manager
+AUTH_TYPE = AUTH_REMOTE_USER
+` + b.getUserRegistrationConfig(authentication) + `
+from security_manager import KerberosSecurityManager
+
+CUSTOM_SECURITY_MANAGER = KerberosSecurityManager
+`
+}
+
+// getKerberosSecurityManagerConfig renders the security manager of the Kerberos authentication.
+// The service ticket of the user is accepted with any principal of the keytab in KRB5_KTNAME,
+// and the realm is stripped from the principal of the user to get the username.
+// gssapi is imported by the login view only, so the other security managers do not depend on it.
+func getKerberosSecurityManagerConfig() string {
+	return `
+
+class KerberosAuthView(AuthRemoteUserView):
+	@expose('/login/')
+	def login(self):
+		if g.user is not None and g.user.is_authenticated:
+			return redirect(self.appbuilder.get_url_for_index)
+
+		authorization = request.headers.get('Authorization', '')
+		if not authorization.startswith('Negotiate '):
+			return Response('Kerberos authentication required', 401, {'WWW-Authenticate': 'Negotiate'})
+
+		import gssapi
+
+		context = gssapi.SecurityContext(creds=gssapi.Credentials(usage='accept'), usage='accept')
+		try:
+			token = context.step(base64.b64decode(authorization[len('Negotiate '):]))
+		except gssapi.exceptions.GSSError as e:
+			logger.warning('SPNEGO authentication failed: %s', e)
+			abort(401)
+		if not context.complete:
+			logger.warning('SPNEGO authentication needs more than one round trip, which is not supported')
+			abort(401)
+
+		principal = str(context.initiator_name)
+		username = principal.split('@', 1)[0]
+		user = self.appbuilder.sm.auth_user_remote_user(username)
+		if user is None:
+			logger.warning('Kerberos user %s is not registered or inactive', principal)
+			abort(403)
+		login_user(user)
+
+		response = redirect(self.appbuilder.get_url_for_index)
+		if token:
+			response.headers['WWW-Authenticate'] = 'Negotiate ' + base64.b64encode(token).decode()
+		return response
+
+
+class KerberosSecurityManager(SupersetSecurityManager):
+	authremoteuserview = KerberosAuthView
+`
+}
+
+// addKerberos mounts the keytab of the HTTP principal of the node service,
+// and points the Kerberos library to it and to the rendered krb5.conf.
+func (b *StatefulSetBuilder) addKerberos(container builder.ContainerBuilder, secretClass string) {
+	b.addSecretVolumeWithAnnotations(
+		container,
+		KerberosVolumeName,
+		secretClass,
+		[]string{string(constants.ServiceScope) + "=" + b.Name, string(constants.PodScope)},
+		map[string]string{constants.AnnotationSecretsKerberosServiceNames: KerberosServiceName},
+	)
+
+	container.AddEnvVars([]corev1.EnvVar{
+		{Name: "KRB5_CONFIG", Value: path.Join(constants.KubedoopConfigDirMount, Krb5ConfigFilename)},
+		{Name: "KRB5_KTNAME", Value: path.Join(constants.KubedoopSecretDir, secretClass, KerberosKeytabFilename)},
+	})
+}
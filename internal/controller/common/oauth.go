@@ -0,0 +1,275 @@
+package common
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+)
+
+// The provider hints of the OIDC authentication class supported by superset.
+// Keycloak, Okta, Azure and Google are discovered from the issuer, GitHub is a plain OAuth2 provider.
+const (
+	ProviderHintKeycloak = "keycloak"
+	ProviderHintGitHub   = "github"
+	ProviderHintGoogle   = "google"
+	ProviderHintAzure    = "azure"
+	ProviderHintOkta     = "okta"
+
+	DefaultOIDCPrincipalClaim   = "preferred_username"
+	DefaultGitHubPrincipalClaim = "login"
+
+	// githubHostname is the hostname of github.com, its API is served from a separate host,
+	// whereas GitHub Enterprise serves it from /api/v3.
+	githubHostname    = "github.com"
+	githubAPIHostname = "api.github.com"
+)
+
+// SupportedProviderHints are the provider hints rendered by getOAuthProviderConfig,
+// an empty hint is handled as keycloak.
+var SupportedProviderHints = []string{
+	ProviderHintKeycloak,
+	ProviderHintGitHub,
+	ProviderHintGoogle,
+	ProviderHintAzure,
+	ProviderHintOkta,
+}
+
+// oauthClaims are the claims of the user info mapped to the superset user, the first present claim is used.
+type oauthClaims struct {
+	Username  []string
+	Email     []string
+	FirstName []string
+	LastName  []string
+	RoleKeys  []string
+}
+
+// getProviderHint returns the provider hint of the OIDC provider, keycloak if not set.
+func getProviderHint(oidcProvider *authv1alpha1.OIDCProvider) string {
+	if oidcProvider.ProviderHint == "" {
+		return ProviderHintKeycloak
+	}
+	return oidcProvider.ProviderHint
+}
+
+// getOAuthClaims returns the claims of the provider hint, the principal claim of the
+// authentication class is used as username.
+func getOAuthClaims(oidcProvider *authv1alpha1.OIDCProvider) oauthClaims {
+	hint := getProviderHint(oidcProvider)
+
+	claims := oauthClaims{
+		Username:  []string{DefaultOIDCPrincipalClaim},
+		Email:     []string{"email"},
+		FirstName: []string{"given_name"},
+		LastName:  []string{"family_name"},
+		RoleKeys:  []string{"groups"},
+	}
+	switch hint {
+	case ProviderHintGitHub:
+		// The user info is assembled by the security manager from the user, emails and teams API,
+		// the role keys are the teams as <org>/<team>.
+		claims.Username = []string{DefaultGitHubPrincipalClaim}
+		claims.RoleKeys = []string{"teams"}
+	case ProviderHintAzure:
+		// The email claim is optional in Azure, the UPN is usually the email.
+		claims.Email = []string{"email", "upn", "preferred_username"}
+		claims.RoleKeys = []string{"roles"}
+	case ProviderHintGoogle:
+		// Google neither sets preferred_username nor exposes the groups of a user in the id token.
+		claims.Username = []string{"email"}
+		claims.RoleKeys = nil
+	}
+
+	if oidcProvider.PrincipalClaim != "" {
+		claims.Username = []string{oidcProvider.PrincipalClaim}
+	}
+	return claims
+}
+
+// getOAuthIssuer returns the issuer url of the provider, without a trailing slash.
+func getOAuthIssuer(oidcProvider *authv1alpha1.OIDCProvider) string {
+	issuer := url.URL{
+		Scheme: "http",
+		Host:   oidcProvider.Hostname,
+		Path:   oidcProvider.RootPath,
+	}
+	if oidcProvider.TLS != nil && oidcProvider.TLS.Verification != nil {
+		issuer.Scheme = "https"
+	}
+
+	if oidcProvider.Port != 0 {
+		issuer.Host += ":" + strconv.Itoa(oidcProvider.Port)
+	}
+	return strings.TrimSuffix(issuer.String(), "/")
+}
+
+// getOAuthEndpointsConfig renders the endpoints of the remote app.
+// The OIDC providers are discovered from the issuer, so only the base url of the API differs.
+// GitHub does not support discovery, its authorize and token urls are rendered instead.
+func getOAuthEndpointsConfig(oidcProvider *authv1alpha1.OIDCProvider) string {
+	issuer := getOAuthIssuer(oidcProvider)
+
+	var apiBaseURL string
+	switch getProviderHint(oidcProvider) {
+	case ProviderHintGitHub:
+		apiBaseURL = issuer + "/api/v3/"
+		if oidcProvider.Hostname == githubHostname {
+			apiBaseURL = strings.Replace(issuer, githubHostname, githubAPIHostname, 1) + "/"
+		}
+		return `            'api_base_url': '` + apiBaseURL + `',    # Base URL for the API
+            'access_token_url': '` + issuer + `/login/oauth/access_token',
+            'authorize_url': '` + issuer + `/login/oauth/authorize',
+`
+	case ProviderHintKeycloak:
+		apiBaseURL = issuer + "/protocol/"
+	case ProviderHintOkta:
+		apiBaseURL = issuer + "/v1/"
+	default:
+		apiBaseURL = issuer + "/"
+	}
+
+	return `            'api_base_url': '` + apiBaseURL + `',    # Base URL for the API
+            'server_metadata_url': '` + issuer + `/.well-known/openid-configuration',
+`
+}
+
+// getOAuthScopes returns the scopes requested from the provider, the extra scopes of the spec are appended.
+func getOAuthScopes(method AuthenticationMethod) []string {
+	scopes := []string{"openid", "email", "profile"}
+	if getProviderHint(method.Provider.OIDC) == ProviderHintGitHub {
+		scopes = []string{"read:user", "user:email", "read:org"}
+	}
+
+	if method.Spec.Oidc != nil {
+		scopes = append(scopes, method.Spec.Oidc.ExtraScopes...)
+	}
+	return scopes
+}
+
+// getOAuthIcon returns the font awesome icon of the login button.
+func getOAuthIcon(oidcProvider *authv1alpha1.OIDCProvider) string {
+	switch getProviderHint(oidcProvider) {
+	case ProviderHintGitHub:
+		return "fa-github"
+	case ProviderHintGoogle:
+		return "fa-google"
+	case ProviderHintAzure:
+		return "fa-windows"
+	default:
+		return "fa-address-card"
+	}
+}
+
+// pythonStringList renders the strings as python list.
+func pythonStringList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, strconv.Quote(value))
+	}
+	return "[" + strings.Join(quoted, ", ") + "]"
+}
+
+// getOAuthProviderConfig renders the OAUTH_PROVIDERS entry of an OIDC authentication class.
+// The provider_hint and claims keys are not read by flask-appbuilder, but by OAuthSecurityManager.
+func getOAuthProviderConfig(method AuthenticationMethod) string {
+	oidcProvider := method.Provider.OIDC
+	claims := getOAuthClaims(oidcProvider)
+
+	clientIDEnv, clientSecretEnv := GetOIDCClientEnvNames(method.Spec.AuthenticationClass)
+
+	return `    {   'name': ` + strconv.Quote(method.Spec.AuthenticationClass) + `,    # Name of the provider
+        'token_key': 'access_token',    # Name of the token in the response of access_token_url
+        'icon': '` + getOAuthIcon(oidcProvider) + `',    # Icon for the provider
+        'provider_hint': '` + getProviderHint(oidcProvider) + `',
+        'claims': {    # Claims of the user info mapped to the user, the first present claim is used
+            'username': ` + pythonStringList(claims.Username) + `,
+            'email': ` + pythonStringList(claims.Email) + `,
+            'first_name': ` + pythonStringList(claims.FirstName) + `,
+            'last_name': ` + pythonStringList(claims.LastName) + `,
+            'role_keys': ` + pythonStringList(claims.RoleKeys) + `,
+        },
+        'remote_app': {
+            'client_id': os.environ.get('` + clientIDEnv + `'),    # Client Id (Identify Superset application)
+            'client_secret': os.environ.get('` + clientSecretEnv + `'),    # Secret for this Client Id (Identify Superset application)
+            'client_kwargs': {
+                'scope': '` + strings.Join(getOAuthScopes(method), " ") + `',               # Scope for the Authorization
+` + getOIDCVerifyConfig(*oidcProvider) + `            },
+` + getOAuthEndpointsConfig(oidcProvider) + `        }
+    },
+`
+}
+
+// validateProviderHint returns an error if the provider hint of the authentication class is not supported.
+func validateProviderHint(authenticationClass string, oidcProvider *authv1alpha1.OIDCProvider) error {
+	hint := getProviderHint(oidcProvider)
+	for _, supported := range SupportedProviderHints {
+		if hint == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("authentication class %s has the unsupported provider hint %s, supported are %s",
+		authenticationClass, hint, strings.Join(SupportedProviderHints, ", "))
+}
+
+// getOAuthSecurityManagerConfig renders the security manager of the OAuth providers.
+// flask-appbuilder only maps the user info of providers with well known names,
+// whereas the providers are named after their authentication class.
+// The user info is read from the id token, or the user info endpoint, of the OIDC providers,
+// and from the API of GitHub, then the claims of the provider are mapped to the user.
+func getOAuthSecurityManagerConfig() string {
+	return `
+
+def get_claim(userinfo, claims, default=''):
+	for claim in claims:
+		if userinfo.get(claim):
+			return userinfo[claim]
+	return default
+
+
+class OAuthSecurityManager(SupersetSecurityManager):
+	def get_oauth_user_info(self, provider, resp):
+		provider_config = next(p for p in self.oauth_providers if p['name'] == provider)
+
+		if provider_config.get('provider_hint') == '` + ProviderHintGitHub + `':
+			userinfo = self.get_github_user_info(provider)
+		else:
+			# authlib parses the id token into the userinfo of the token response,
+			# fall back to the userinfo endpoint if the id token is missing.
+			userinfo = resp.get('userinfo')
+			if not userinfo:
+				userinfo = self.oauth_remotes[provider].userinfo()
+		logger.debug('User info of OAuth provider %s: %s', provider, userinfo)
+
+		claims = provider_config['claims']
+		role_keys = get_claim(userinfo, claims['role_keys'], [])
+		if isinstance(role_keys, str):
+			role_keys = [role_keys]
+
+		return {
+			'username': get_claim(userinfo, claims['username']),
+			'first_name': get_claim(userinfo, claims['first_name']),
+			'last_name': get_claim(userinfo, claims['last_name']),
+			'email': get_claim(userinfo, claims['email']),
+			'role_keys': role_keys,
+		}
+
+	def get_github_user_info(self, provider):
+		remote = self.oauth_remotes[provider]
+
+		userinfo = remote.get('user').json()
+		# The email is only part of the user if it is public.
+		if not userinfo.get('email'):
+			emails = remote.get('user/emails').json()
+			userinfo['email'] = next((e['email'] for e in emails if e.get('primary')), '')
+
+		name = (userinfo.get('name') or '').split(' ', 1)
+		userinfo['given_name'] = name[0]
+		userinfo['family_name'] = name[1] if len(name) > 1 else ''
+
+		teams = remote.get('user/teams').json()
+		userinfo['teams'] = [team['organization']['login'] + '/' + team['slug'] for team in teams]
+		return userinfo
+`
+}
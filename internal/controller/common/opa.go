@@ -0,0 +1,122 @@
+package common
+
+import (
+	"strconv"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	// OpaDiscoveryConfigMapKey is the key of the url of the OPA server in the OPA discovery ConfigMap.
+	OpaDiscoveryConfigMapKey = "OPA"
+
+	DefaultOpaPackage = "superset"
+	OpaUserRolesRule  = "user_roles"
+)
+
+// InjectOpa adds the OPA_URL env var from the OPA discovery ConfigMap.
+func InjectOpa(opa *supersetv1alpha1.OpaSpec, builder builder.ContainerBuilder) {
+	builder.AddEnvVars([]corev1.EnvVar{
+		{
+			Name: "OPA_URL",
+			ValueFrom: &corev1.EnvVarSource{
+				ConfigMapKeyRef: &corev1.ConfigMapKeySelector{
+					Key:                  OpaDiscoveryConfigMapKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: opa.ConfigMapName},
+				},
+			},
+		},
+	})
+}
+
+// getOpaConfig wraps the security manager of the authentication, or the default one,
+// so the roles are resolved from OPA at login.
+func (b *SupersetConfigMapBuilder) getOpaConfig(opa *supersetv1alpha1.OpaSpec) string {
+	pkg := opa.Package
+	if pkg == "" {
+		pkg = DefaultOpaPackage
+	}
+
+	return `
+# Resolve the roles of the users from OPA at login, they replace the roles of the role mapping.
+OPA_URL = os.environ.get('OPA_URL')
+OPA_PACKAGE = ` + strconv.Quote(pkg) + `
+OPA_RULE = '` + OpaUserRolesRule + `'
+
+from security_manager import with_opa_roles
+
+CUSTOM_SECURITY_MANAGER = with_opa_roles(globals().get('CUSTOM_SECURITY_MANAGER'))
+`
+}
+
+// getOpaSecurityManagerConfig renders the security manager mixin resolving the roles from OPA.
+// update_user_auth_stat is called by every authentication type after a successful login,
+// before the user is saved. If OPA can not be queried or returns no known role, the login is rejected
+// and the stored roles of the user are kept, so an outage of OPA does not strip the roles of the users.
+func getOpaSecurityManagerConfig() string {
+	return `
+
+class OpaLoginRejected(Exception):
+	pass
+
+
+class OpaRolesMixin:
+	def update_user_auth_stat(self, user, success=True):
+		if success:
+			roles = self.get_opa_roles(user)
+			if not roles:
+				raise OpaLoginRejected('OPA returned no known role for user {}'.format(user.username))
+			user.roles = roles
+		super().update_user_auth_stat(user, success)
+
+	def get_opa_roles(self, user):
+		config = current_app.config
+		url = '{}/v1/data/{}/{}'.format(
+			config['OPA_URL'].rstrip('/'), config['OPA_PACKAGE'].replace('.', '/'), config['OPA_RULE'])
+		try:
+			response = requests.post(
+				url, json={'input': {'username': user.username, 'email': user.email}}, timeout=10)
+			response.raise_for_status()
+			role_names = response.json().get('result', [])
+		except (requests.RequestException, ValueError) as e:
+			logger.error('Resolving the roles of user %s from OPA failed: %s', user.username, e)
+			return None
+
+		roles = []
+		for role_name in role_names:
+			role = self.find_role(role_name)
+			if role is None:
+				logger.warning('OPA returned the unknown role %s for user %s', role_name, user.username)
+				continue
+			roles.append(role)
+		return roles
+
+	def opa_authenticate(self, auth_user, *args, **kwargs):
+		try:
+			return auth_user(*args, **kwargs)
+		except OpaLoginRejected as e:
+			logger.error('Login rejected: %s', e)
+			self.get_session.rollback()
+			return None
+
+	def auth_user_db(self, *args, **kwargs):
+		return self.opa_authenticate(super().auth_user_db, *args, **kwargs)
+
+	def auth_user_ldap(self, *args, **kwargs):
+		return self.opa_authenticate(super().auth_user_ldap, *args, **kwargs)
+
+	def auth_user_oauth(self, *args, **kwargs):
+		return self.opa_authenticate(super().auth_user_oauth, *args, **kwargs)
+
+	def auth_user_remote_user(self, *args, **kwargs):
+		return self.opa_authenticate(super().auth_user_remote_user, *args, **kwargs)
+
+
+def with_opa_roles(security_manager=None):
+	base = security_manager or SupersetSecurityManager
+	return type('Opa' + base.__name__, (OpaRolesMixin, base), {})
+`
+}
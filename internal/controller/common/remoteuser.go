@@ -0,0 +1,183 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+
+	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	// DefaultRemoteUserHeader is the username header of most authenticating proxies.
+	DefaultRemoteUserHeader = "X-Forwarded-User"
+	// DefaultProxyRemoteUserHeader is the username header of the oauth2-proxy sidecar,
+	// X-Forwarded-User is the subject of the id token.
+	DefaultProxyRemoteUserHeader = "X-Forwarded-Preferred-Username"
+
+	AuthProxyContainerName = "oauth2-proxy"
+	AuthProxyPortName      = "oauth2-proxy"
+	AuthProxyPort          = 4180
+	AuthProxyTLSVolumeName = "oauth2-proxy-tls"
+
+	// The keys of the oauth2-proxy secret, CLIENT_ID and CLIENT_SECRET are shared with the OIDC client credentials.
+	AuthProxyCookieSecretSecretKey = "COOKIE_SECRET"
+)
+
+// GetRemoteUserHeader returns the header containing the username of the authenticated user.
+func GetRemoteUserHeader(remoteUser *supersetv1alpha1.RemoteUserAuthenticationSpec) string {
+	if remoteUser.Header != "" {
+		return remoteUser.Header
+	}
+	if remoteUser.Proxy != nil {
+		return DefaultProxyRemoteUserHeader
+	}
+	return DefaultRemoteUserHeader
+}
+
+// ResolveAuthProxyProvider returns the OIDC provider the oauth2-proxy sidecar authenticates against,
+// nil if the sidecar is disabled.
+func ResolveAuthProxyProvider(
+	ctx context.Context,
+	client *client.Client,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+) (*authv1alpha1.OIDCProvider, error) {
+	if clusterConfig == nil || clusterConfig.RemoteUserAuthentication == nil || clusterConfig.RemoteUserAuthentication.Proxy == nil {
+		return nil, nil
+	}
+
+	name := clusterConfig.RemoteUserAuthentication.Proxy.AuthenticationClass
+	authClass := &authv1alpha1.AuthenticationClass{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: client.GetOwnerNamespace(),
+		},
+	}
+	if err := client.GetWithObject(ctx, authClass); err != nil {
+		return nil, err
+	}
+
+	if authClass.Spec.AuthenticationProvider == nil || authClass.Spec.AuthenticationProvider.OIDC == nil {
+		return nil, fmt.Errorf("authentication class %s of the auth proxy is not an OIDC provider", name)
+	}
+	return authClass.Spec.AuthenticationProvider.OIDC, nil
+}
+
+// getRemoteUserConfig renders the remote user authentication, the username is read from the header
+// set by the authenticating proxy.
+func (b *SupersetConfigMapBuilder) getRemoteUserConfig(remoteUser *supersetv1alpha1.RemoteUserAuthenticationSpec) string {
+	return `
+# Set the authentication type to remote user, the username is read from a header set by the authenticating proxy
+AUTH_TYPE = AUTH_REMOTE_USER
+` + renderUserRegistrationConfig(remoteUser.UserRegistration, remoteUser.UserRegistrationRole) + `AUTH_REMOTE_USER_HEADER = ` + strconv.Quote(GetRemoteUserHeader(remoteUser)) + `
+
+from security_manager import RemoteUserSecurityManager
+
+CUSTOM_SECURITY_MANAGER = RemoteUserSecurityManager
+`
+}
+
+// getRemoteUserSecurityManagerConfig renders the security manager of the remote user authentication.
+// flask-appbuilder reads the username from the WSGI environ, it is replaced by the configured header.
+// A session of another user is replaced, so the identity always follows the proxy.
+func getRemoteUserSecurityManagerConfig() string {
+	return `
+
+class RemoteUserAuthView(AuthRemoteUserView):
+	@expose('/login/')
+	def login(self):
+		header = current_app.config['AUTH_REMOTE_USER_HEADER']
+		username = request.headers.get(header)
+		if not username:
+			logger.warning('Request without the remote user header %s', header)
+			abort(401)
+
+		if g.user is not None and g.user.is_authenticated:
+			if g.user.username == username:
+				return redirect(self.appbuilder.get_url_for_index)
+			logout_user()
+
+		user = self.appbuilder.sm.auth_user_remote_user(username)
+		if user is None:
+			logger.warning('Remote user %s is not registered or inactive', username)
+			abort(403)
+		login_user(user)
+		return redirect(self.appbuilder.get_url_for_index)
+
+
+class RemoteUserSecurityManager(SupersetSecurityManager):
+	authremoteuserview = RemoteUserAuthView
+`
+}
+
+// addAuthProxy adds the oauth2-proxy sidecar, it authenticates the users against the OIDC provider
+// and forwards the requests with the user headers to superset, which only listens on localhost.
+func (b *StatefulSetBuilder) addAuthProxy(proxy *supersetv1alpha1.AuthProxySpec, oidcProvider *authv1alpha1.OIDCProvider) {
+	container := builder.NewContainer(AuthProxyContainerName, &util.Image{Custom: proxy.Image})
+
+	args := []string{
+		"--http-address=0.0.0.0:" + strconv.Itoa(AuthProxyPort),
+		"--upstream=http://127.0.0.1:" + strconv.Itoa(int(b.getAppPort())) + "/",
+		"--provider=oidc",
+		"--oidc-issuer-url=" + getOAuthIssuer(oidcProvider),
+		"--scope=openid email profile",
+		"--email-domain=*",
+		"--pass-user-headers=true",
+		"--skip-provider-button=true",
+		// The probes of superset are sent through the proxy.
+		"--skip-auth-route=GET=^/health$",
+		// The sidecar serves plain http, TLS is terminated in front of it if at all.
+		"--cookie-secure=false",
+	}
+	if oidcProvider.TLS != nil && oidcProvider.TLS.Verification != nil {
+		if oidcProvider.TLS.Verification.Server == nil {
+			args = append(args, "--ssl-insecure-skip-verify=true")
+		} else if caSecretClass := GetOIDCCASecretClass(oidcProvider); caSecretClass != "" {
+			args = append(args, "--provider-ca-file="+path.Join(constants.KubedoopSecretDir, caSecretClass, "ca.crt"))
+			b.addSecretVolume(container, AuthProxyTLSVolumeName, caSecretClass, nil)
+		}
+	}
+	container.SetArgs(args)
+
+	secretKeyRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key:                  key,
+				LocalObjectReference: corev1.LocalObjectReference{Name: proxy.ClientCredentialsSecret},
+			},
+		}
+	}
+	container.AddEnvVars([]corev1.EnvVar{
+		{Name: "OAUTH2_PROXY_CLIENT_ID", ValueFrom: secretKeyRef(OIDCClientIDSecretKey)},
+		{Name: "OAUTH2_PROXY_CLIENT_SECRET", ValueFrom: secretKeyRef(OIDCClientSecretSecretKey)},
+		{Name: "OAUTH2_PROXY_COOKIE_SECRET", ValueFrom: secretKeyRef(AuthProxyCookieSecretSecretKey)},
+	})
+
+	container.AddPorts([]corev1.ContainerPort{{Name: AuthProxyPortName, ContainerPort: AuthProxyPort}})
+	probe := &corev1.Probe{
+		ProbeHandler: corev1.ProbeHandler{
+			HTTPGet: &corev1.HTTPGetAction{
+				Path: "/ping",
+				Port: intstr.FromString(AuthProxyPortName),
+			},
+		},
+		PeriodSeconds:    10,
+		TimeoutSeconds:   5,
+		FailureThreshold: 3,
+		SuccessThreshold: 1,
+	}
+	container.SetLivenessProbe(probe)
+	container.SetReadinessProbe(probe)
+
+	b.AddContainer(container.Build())
+}
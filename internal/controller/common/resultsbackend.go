@@ -0,0 +1,238 @@
+package common
+
+import (
+	"path"
+	"strconv"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	SupersetResultsBackendFilename = "results_backend.py"
+
+	// The keys of the S3 credentials, in the secret or as files of the secret class.
+	S3AccessKeySecretKey = "ACCESS_KEY"
+	S3SecretKeySecretKey = "SECRET_KEY"
+
+	ResultsBackendS3AccessKeyEnv = "RESULTS_BACKEND_S3_ACCESS_KEY"
+	ResultsBackendS3SecretKeyEnv = "RESULTS_BACKEND_S3_SECRET_KEY"
+
+	ResultsBackendS3CredentialsVolumeName = "results-backend-s3-credentials"
+
+	DefaultResultsBackendS3KeyPrefix = "superset/results/"
+	DefaultResultsBackendS3Region    = "us-east-1"
+)
+
+// InjectResultsBackend adds the env vars of the S3 credentials, if they are read from a secret.
+func InjectResultsBackend(resultsBackend *supersetv1alpha1.ResultsBackendSpec, builder builder.ContainerBuilder) {
+	secret := resultsBackend.S3.Credentials.Secret
+	if secret == "" {
+		return
+	}
+	secretKeyRef := func(key string) *corev1.EnvVarSource {
+		return &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				Key:                  key,
+				LocalObjectReference: corev1.LocalObjectReference{Name: secret},
+			},
+		}
+	}
+	builder.AddEnvVars([]corev1.EnvVar{
+		{Name: ResultsBackendS3AccessKeyEnv, ValueFrom: secretKeyRef(S3AccessKeySecretKey)},
+		{Name: ResultsBackendS3SecretKeyEnv, ValueFrom: secretKeyRef(S3SecretKeySecretKey)},
+	})
+}
+
+// NewResultsBackendCredentialsVolume returns the volume of the S3 credentials, nil if they are read from a secret.
+// superset_config.py reads them at import, so every container loading it mounts the volume, including the init job.
+func NewResultsBackendCredentialsVolume(resultsBackend *supersetv1alpha1.ResultsBackendSpec) (*corev1.Volume, *corev1.VolumeMount) {
+	if resultsBackend == nil || resultsBackend.S3.Credentials.SecretClass == "" {
+		return nil, nil
+	}
+	return NewSecretVolume(ResultsBackendS3CredentialsVolumeName, resultsBackend.S3.Credentials.SecretClass, nil, nil)
+}
+
+// addResultsBackendCredentials mounts the S3 credentials of the secret class.
+func (b *StatefulSetBuilder) addResultsBackendCredentials(container builder.ContainerBuilder, resultsBackend *supersetv1alpha1.ResultsBackendSpec) {
+	b.addSecretVolume(container, ResultsBackendS3CredentialsVolumeName, resultsBackend.S3.Credentials.SecretClass, nil)
+}
+
+// getResultsBackendCredentialsConfig renders the S3 credentials, read from the env or the files of the secret class.
+func getResultsBackendCredentialsConfig(credentials supersetv1alpha1.ResultsBackendS3CredentialsSpec) string {
+	if credentials.SecretClass == "" {
+		return `RESULTS_BACKEND_S3_ACCESS_KEY = os.environ.get('` + ResultsBackendS3AccessKeyEnv + `')
+RESULTS_BACKEND_S3_SECRET_KEY = os.environ.get('` + ResultsBackendS3SecretKeyEnv + `')
+`
+	}
+
+	mountPath := path.Join(constants.KubedoopSecretDir, credentials.SecretClass)
+	return `with open('` + path.Join(mountPath, S3AccessKeySecretKey) + `', 'r') as f:
+	RESULTS_BACKEND_S3_ACCESS_KEY = f.read().strip()
+
+with open('` + path.Join(mountPath, S3SecretKeySecretKey) + `', 'r') as f:
+	RESULTS_BACKEND_S3_SECRET_KEY = f.read().strip()
+`
+}
+
+// getResultsBackendConfig renders the S3 bucket as RESULTS_BACKEND, the workers store the results
+// of the async queries in it and the web server reads them, so they survive restarts of the pods.
+func (b *SupersetConfigMapBuilder) getResultsBackendConfig(resultsBackend *supersetv1alpha1.ResultsBackendSpec) string {
+	s3 := resultsBackend.S3
+	keyPrefix := s3.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = DefaultResultsBackendS3KeyPrefix
+	}
+	region := s3.Region
+	if region == "" {
+		region = DefaultResultsBackendS3Region
+	}
+
+	config := `
+# Store the results of the async queries in S3
+from results_backend import S3ResultsCache
+
+` + getResultsBackendCredentialsConfig(s3.Credentials) + `
+RESULTS_BACKEND = S3ResultsCache(
+	endpoint_url=` + strconv.Quote(s3.Endpoint) + `,
+	bucket=` + strconv.Quote(s3.Bucket) + `,
+	key_prefix=` + strconv.Quote(keyPrefix) + `,
+	region_name=` + strconv.Quote(region) + `,
+	path_style=` + pythonBool(s3.PathStyle) + `,
+	access_key=RESULTS_BACKEND_S3_ACCESS_KEY,
+	secret_key=RESULTS_BACKEND_S3_SECRET_KEY,
+)
+`
+	if resultsBackend.GlobalAsyncQueries != nil && *resultsBackend.GlobalAsyncQueries && b.ClusterConfig.Cache != nil {
+		config += b.getGlobalAsyncQueriesConfig(b.ClusterConfig.Cache)
+	}
+	return config
+}
+
+// getGlobalAsyncQueriesConfig renders the async queries of charts and dashboards. The events of the queries
+// are sent through the redis database of the caches. The JWT secret of the async events cookie is derived
+// from the secret key, so it is the same in every pod.
+// Superset 4.0 only connects to a single redis, the cache backend with sentinel is read by superset 4.1 and later.
+func (b *SupersetConfigMapBuilder) getGlobalAsyncQueriesConfig(cache *supersetv1alpha1.CacheSpec) string {
+	cacheDB := strconv.Itoa(int(getRedisDBs(cache).cache))
+
+	config := `
+# Run the queries of charts and dashboards asynchronously in the workers
+import hashlib
+
+FEATURE_FLAGS['GLOBAL_ASYNC_QUERIES'] = True
+GLOBAL_ASYNC_QUERIES_JWT_SECRET = hashlib.sha256(('global-async-queries:' + SECRET_KEY).encode()).hexdigest()
+`
+	if b.ClusterConfig.Session != nil && b.ClusterConfig.Session.CookieSecure != nil {
+		config += `GLOBAL_ASYNC_QUERIES_JWT_COOKIE_SECURE = ` + pythonBool(*b.ClusterConfig.Session.CookieSecure) + `
+`
+	}
+
+	if cache.Sentinel != nil {
+		return config + `GLOBAL_ASYNC_QUERIES_CACHE_BACKEND = {
+	**CACHE_CONFIG,
+	'CACHE_REDIS_SSL': bool(REDIS_SSL_OPTIONS),
+	'CACHE_REDIS_SSL_CERT_REQS': REDIS_SSL_OPTIONS.get('ssl_cert_reqs', 'required'),
+	'CACHE_REDIS_SSL_CA_CERTS': REDIS_SSL_OPTIONS.get('ssl_ca_certs'),
+}
+`
+	}
+	return config + `GLOBAL_ASYNC_QUERIES_REDIS_CONFIG = {
+	'host': REDIS_HOST,
+	'port': REDIS_PORT,
+	'db': ` + cacheDB + `,
+	'username': REDIS_USERNAME,
+	'password': REDIS_PASSWORD,
+	**({'ssl': True, **REDIS_SSL_OPTIONS} if REDIS_SSL_OPTIONS else {}),
+}
+`
+}
+
+// getResultsBackendModuleConfig returns the module of the S3 results backend. S3 has no expiry per object,
+// so the expiry is stored in the metadata of the object and checked when it is read.
+// The values are pickled, as the cache backends of flask-caching do.
+func (b *SupersetConfigMapBuilder) getResultsBackendModuleConfig() string {
+	config := `
+import logging
+import pickle
+import time
+
+import boto3
+from botocore.config import Config
+from botocore.exceptions import BotoCoreError, ClientError
+from cachelib.base import BaseCache
+
+logger = logging.getLogger(__name__)
+
+
+class S3ResultsCache(BaseCache):
+	def __init__(self, endpoint_url, bucket, key_prefix='', region_name=None, path_style=False,
+			access_key=None, secret_key=None, default_timeout=0):
+		super().__init__(default_timeout)
+		self.bucket = bucket
+		self.key_prefix = key_prefix
+		self.client = boto3.client(
+			's3',
+			endpoint_url=endpoint_url,
+			region_name=region_name,
+			aws_access_key_id=access_key,
+			aws_secret_access_key=secret_key,
+			config=Config(s3={'addressing_style': 'path' if path_style else 'virtual'}),
+		)
+
+	def _get_object(self, key, head=False):
+		get = self.client.head_object if head else self.client.get_object
+		try:
+			response = get(Bucket=self.bucket, Key=self.key_prefix + key)
+		except ClientError as e:
+			if e.response['Error']['Code'] not in ('404', 'NoSuchKey'):
+				logger.error('Reading the result %s from S3 failed: %s', key, e)
+			return None
+		except BotoCoreError as e:
+			logger.error('Reading the result %s from S3 failed: %s', key, e)
+			return None
+		expires = int(response['Metadata'].get('expires', 0))
+		if expires and expires < time.time():
+			self.delete(key)
+			return None
+		return response
+
+	def get(self, key):
+		response = self._get_object(key)
+		if response is None:
+			return None
+		return pickle.loads(response['Body'].read())
+
+	def has(self, key):
+		return self._get_object(key, head=True) is not None
+
+	def set(self, key, value, timeout=None):
+		timeout = self._normalize_timeout(timeout)
+		metadata = {'expires': str(int(time.time()) + timeout)} if timeout > 0 else {}
+		try:
+			self.client.put_object(
+				Bucket=self.bucket, Key=self.key_prefix + key, Body=pickle.dumps(value), Metadata=metadata)
+		except (BotoCoreError, ClientError) as e:
+			logger.error('Writing the result %s to S3 failed: %s', key, e)
+			return False
+		return True
+
+	def add(self, key, value, timeout=None):
+		if self.has(key):
+			return False
+		return self.set(key, value, timeout)
+
+	def delete(self, key):
+		try:
+			self.client.delete_object(Bucket=self.bucket, Key=self.key_prefix + key)
+		except (BotoCoreError, ClientError) as e:
+			logger.error('Deleting the result %s from S3 failed: %s', key, e)
+			return False
+		return True
+`
+	return util.IndentTab4Spaces(config)
+}
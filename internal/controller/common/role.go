@@ -0,0 +1,9 @@
+package common
+
+// Role names of a superset cluster.
+// The role name is used as the main container name and selects the process started in it.
+const (
+	NodeRoleName   = "node"
+	WorkerRoleName = "worker"
+	BeatRoleName   = "beat"
+)
@@ -0,0 +1,294 @@
+package common
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	// SecretKeySecretKey is the key of the flask app secret key in the secret key secret.
+	SecretKeySecretKey = "SUPERSET_SECRET_KEY"
+	// PreviousSecretKeySecretKey is the key of the secret key before the last rotation, it is optional
+	// and removed from the operator owned secret once the stored secrets are re-encrypted.
+	PreviousSecretKeySecretKey = "SUPERSET_PREVIOUS_SECRET_KEY"
+
+	// AnnotationSecretKeyHash is the pod annotation which rolls the pods when the secret key is rotated.
+	AnnotationSecretKeyHash = "superset.kubedoop.dev/secret-key-hash"
+
+	// legacySecretKeyCredentialsKey is the key of the secret key in the credentials secret,
+	// it is only read to seed the generated secret of existing clusters.
+	legacySecretKeyCredentialsKey = "appSecretKey"
+
+	secretKeyLength = 32
+)
+
+var secretKeyLogger = ctrl.Log.WithName("reconciler").WithName("secretkey")
+
+// GetSecretKeySecretName returns the name of the secret containing the flask app secret key,
+// it is the existing secret if set, otherwise the secret generated by the operator.
+func GetSecretKeySecretName(clusterName string, clusterConfig *supersetv1alpha1.ClusterConfigSpec) string {
+	if clusterConfig != nil && clusterConfig.AppSecretKey != nil && clusterConfig.AppSecretKey.ExistSecret != "" {
+		return clusterConfig.AppSecretKey.ExistSecret
+	}
+	return clusterName + "-secret-key"
+}
+
+// InjectSecretKey adds the SECRET_KEY env var from the secret key secret.
+func InjectSecretKey(clusterName string, clusterConfig *supersetv1alpha1.ClusterConfigSpec, builder builder.ContainerBuilder) {
+	secretName := GetSecretKeySecretName(clusterName, clusterConfig)
+	builder.AddEnvVars([]corev1.EnvVar{
+		{
+			Name: "SECRET_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key:                  SecretKeySecretKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				},
+			},
+		},
+	})
+}
+
+// InjectPreviousSecretKey adds the PREVIOUS_SECRET_KEY env var from the secret key secret,
+// only the re-encrypt job decrypts the stored secrets with it.
+func InjectPreviousSecretKey(clusterName string, clusterConfig *supersetv1alpha1.ClusterConfigSpec, builder builder.ContainerBuilder) {
+	secretName := GetSecretKeySecretName(clusterName, clusterConfig)
+	builder.AddEnvVars([]corev1.EnvVar{
+		{
+			Name: "PREVIOUS_SECRET_KEY",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					Key:                  PreviousSecretKeySecretKey,
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Optional:             &[]bool{true}[0],
+				},
+			},
+		},
+	})
+}
+
+// GetSecretKeys returns the current and previous secret key, both empty if the secret does not exist yet.
+func GetSecretKeys(
+	ctx context.Context,
+	client *client.Client,
+	clusterName string,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+) (current string, previous string, err error) {
+	secret := &corev1.Secret{}
+	if err := client.GetWithOwnerNamespace(ctx, GetSecretKeySecretName(clusterName, clusterConfig), secret); err != nil {
+		if ctrlclient.IgnoreNotFound(err) == nil {
+			return "", "", nil
+		}
+		return "", "", err
+	}
+	return string(secret.Data[SecretKeySecretKey]), string(secret.Data[PreviousSecretKeySecretKey]), nil
+}
+
+// ClearPreviousSecretKey removes the previous key from the operator owned secret,
+// once the stored secrets are re-encrypted it is not needed anymore.
+// An existing secret is managed by the user, it is left as is. It returns whether the key was removed.
+func ClearPreviousSecretKey(
+	ctx context.Context,
+	client *client.Client,
+	clusterName string,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+) (bool, error) {
+	if clusterConfig.AppSecretKey != nil && clusterConfig.AppSecretKey.ExistSecret != "" {
+		return false, nil
+	}
+	secret := &corev1.Secret{}
+	if err := client.GetWithOwnerNamespace(ctx, GetSecretKeySecretName(clusterName, clusterConfig), secret); err != nil {
+		return false, err
+	}
+	if _, ok := secret.Data[PreviousSecretKeySecretKey]; !ok {
+		return false, nil
+	}
+	secretKeyLogger.Info("Removing the previous secret key, the stored secrets are re-encrypted",
+		"namespace", secret.Namespace, "name", secret.Name)
+	delete(secret.Data, PreviousSecretKeySecretKey)
+	if err := client.Client.Update(ctx, secret); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// HashSecretKeys returns a short hash identifying a secret key rotation, the keys are never exposed.
+func HashSecretKeys(current, previous string) string {
+	hash := sha256.Sum256([]byte(current + "\n" + previous))
+	return hex.EncodeToString(hash[:])[:16]
+}
+
+var _ reconciler.Reconciler = &SecretKeyReconciler{}
+
+// SecretKeyReconciler ensures the flask app secret key secret.
+//
+// When an existing secret is referenced, it is only checked to contain the secret key.
+// Otherwise the operator owns the secret, and its key is, in order of precedence:
+//   - the secret key set in the spec
+//   - the key already persisted in the secret
+//   - the `appSecretKey` of the credentials secret, so existing clusters keep their key
+//   - a randomly generated key
+//
+// When the key set in the spec changes, the persisted key is kept as the previous key,
+// so the stored secrets can be re-encrypted with the new one. The re-encrypt job removes the previous key
+// once it succeeded, until then a further change of the key is deferred, as the secrets stored
+// with the previous key could not be decrypted anymore.
+// An existing secret can provide the previous key in `SUPERSET_PREVIOUS_SECRET_KEY` itself.
+type SecretKeyReconciler struct {
+	Client        *client.Client
+	ClusterInfo   reconciler.ClusterInfo
+	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
+}
+
+func NewSecretKeyReconciler(
+	client *client.Client,
+	clusterInfo reconciler.ClusterInfo,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+) *SecretKeyReconciler {
+	return &SecretKeyReconciler{
+		Client:        client,
+		ClusterInfo:   clusterInfo,
+		ClusterConfig: clusterConfig,
+	}
+}
+
+func (r *SecretKeyReconciler) GetName() string {
+	return GetSecretKeySecretName(r.ClusterInfo.GetClusterName(), r.ClusterConfig)
+}
+
+func (r *SecretKeyReconciler) GetNamespace() string {
+	return r.Client.GetOwnerNamespace()
+}
+
+func (r *SecretKeyReconciler) GetClient() *client.Client {
+	return r.Client
+}
+
+func (r *SecretKeyReconciler) isExistSecret() bool {
+	return r.ClusterConfig.AppSecretKey != nil && r.ClusterConfig.AppSecretKey.ExistSecret != ""
+}
+
+// getSecret returns the secret with the given name, nil if it does not exist.
+func (r *SecretKeyReconciler) getSecret(ctx context.Context, name string) (*corev1.Secret, error) {
+	secret := &corev1.Secret{}
+	if err := r.Client.GetWithOwnerNamespace(ctx, name, secret); err != nil {
+		if ctrlclient.IgnoreNotFound(err) == nil {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return secret, nil
+}
+
+// getSecretKey returns the secret key to persist in the operator owned secret.
+func (r *SecretKeyReconciler) getSecretKey(ctx context.Context, current *corev1.Secret) (string, error) {
+	if r.ClusterConfig.AppSecretKey != nil && r.ClusterConfig.AppSecretKey.SecretKey != "" {
+		return r.ClusterConfig.AppSecretKey.SecretKey, nil
+	}
+
+	if current != nil && len(current.Data[SecretKeySecretKey]) > 0 {
+		return string(current.Data[SecretKeySecretKey]), nil
+	}
+
+	if r.ClusterConfig.CredentialsSecret != "" {
+		credentials, err := r.getSecret(ctx, r.ClusterConfig.CredentialsSecret)
+		if err != nil {
+			return "", err
+		}
+		if credentials != nil && len(credentials.Data[legacySecretKeyCredentialsKey]) > 0 {
+			secretKeyLogger.Info("Seeding the secret key from the credentials secret",
+				"namespace", r.GetNamespace(), "credentialsSecret", r.ClusterConfig.CredentialsSecret)
+			return string(credentials.Data[legacySecretKeyCredentialsKey]), nil
+		}
+	}
+
+	key := make([]byte, secretKeyLength)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	secretKeyLogger.Info("Generated a new secret key", "namespace", r.GetNamespace(), "name", r.GetName())
+	return hex.EncodeToString(key), nil
+}
+
+func (r *SecretKeyReconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	current, err := r.getSecret(ctx, r.GetName())
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if r.isExistSecret() {
+		if current == nil {
+			return ctrl.Result{}, fmt.Errorf("secret key secret %s/%s not found", r.GetNamespace(), r.GetName())
+		}
+		if len(current.Data[SecretKeySecretKey]) == 0 {
+			return ctrl.Result{}, fmt.Errorf("secret key secret %s/%s does not contain the key %s",
+				r.GetNamespace(), r.GetName(), SecretKeySecretKey)
+		}
+		return ctrl.Result{}, nil
+	}
+
+	key, err := r.getSecretKey(ctx, current)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	data := map[string][]byte{
+		SecretKeySecretKey: []byte(key),
+	}
+	if current != nil {
+		currentKey := current.Data[SecretKeySecretKey]
+		previousKey := current.Data[PreviousSecretKeySecretKey]
+		switch {
+		case len(currentKey) > 0 && string(currentKey) != key && len(previousKey) > 0:
+			secretKeyLogger.Info("Deferring the secret key rotation until the stored secrets are re-encrypted with the current key",
+				"namespace", r.GetNamespace(), "name", r.GetName())
+			data[SecretKeySecretKey] = currentKey
+			data[PreviousSecretKeySecretKey] = previousKey
+		case len(currentKey) > 0 && string(currentKey) != key:
+			secretKeyLogger.Info("Rotating the secret key", "namespace", r.GetNamespace(), "name", r.GetName())
+			data[PreviousSecretKeySecretKey] = currentKey
+		case len(previousKey) > 0:
+			data[PreviousSecretKeySecretKey] = previousKey
+		}
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      r.GetName(),
+			Namespace: r.GetNamespace(),
+			Labels:    r.ClusterInfo.GetLabels(),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+	mutated, err := r.Client.CreateOrUpdate(ctx, secret)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// The following resources read the keys back from the cache, requeue until it observed the change.
+	if mutated {
+		return ctrl.Result{RequeueAfter: time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// Ready always returns an empty result, the secret is ready once reconciled.
+func (r *SecretKeyReconciler) Ready(ctx context.Context) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,29 @@
+package common
+
+import (
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// Common annotations for Prometheus scraping
+func GetPrometheusAnnotations(ports []corev1.ContainerPort) map[string]string {
+	metricsPort := 0
+	for _, port := range ports {
+		if port.Name == "metrics" {
+			metricsPort = int(port.ContainerPort)
+			break
+		}
+	}
+
+	if metricsPort > 0 {
+		return map[string]string{
+			"prometheus.io/scrape": "true",
+			"prometheus.io/port":   strconv.Itoa(metricsPort),
+			"prometheus.io/path":   "/metrics",
+			"prometheus.io/scheme": "http",
+		}
+	}
+
+	return nil
+}
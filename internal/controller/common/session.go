@@ -0,0 +1,72 @@
+package common
+
+import (
+	"strconv"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+)
+
+const (
+	SessionBackendCookie = "cookie"
+	SessionBackendRedis  = "redis"
+)
+
+// getSessionConfig renders the session storage and the flags of the session cookie,
+// the flags not set in the spec keep the defaults of superset.
+func (b *SupersetConfigMapBuilder) getSessionConfig(session *supersetv1alpha1.SessionSpec) string {
+	config := ""
+	if session.Backend == SessionBackendRedis && b.ClusterConfig.Cache != nil {
+		config += b.getRedisSessionConfig(b.ClusterConfig.Cache)
+	}
+
+	cookieConfig := ""
+	if session.CookieSecure != nil {
+		cookieConfig += `SESSION_COOKIE_SECURE = ` + pythonBool(*session.CookieSecure) + `
+`
+	}
+	if session.CookieSameSite != "" {
+		cookieConfig += `SESSION_COOKIE_SAMESITE = ` + strconv.Quote(session.CookieSameSite) + `
+`
+	}
+	if session.CookieHTTPOnly != nil {
+		cookieConfig += `SESSION_COOKIE_HTTPONLY = ` + pythonBool(*session.CookieHTTPOnly) + `
+`
+	}
+	if cookieConfig != "" {
+		config += `
+` + cookieConfig
+	}
+	return config
+}
+
+// getRedisSessionConfig renders the server side sessions of flask-session in redis.
+// The cookie only contains the signed session id, so a session is revoked by deleting it from redis.
+func (b *SupersetConfigMapBuilder) getRedisSessionConfig(cache *supersetv1alpha1.CacheSpec) string {
+	sessionDB := strconv.Itoa(int(getRedisDBs(cache).session))
+
+	client := `import redis
+
+SESSION_REDIS = redis.from_url(get_redis_url(` + sessionDB + `))
+`
+	if cache.Sentinel != nil {
+		client = `from redis.sentinel import Sentinel
+
+SESSION_REDIS = Sentinel(
+	REDIS_SENTINELS,
+	sentinel_kwargs=REDIS_SENTINEL_KWARGS,
+	username=REDIS_USERNAME,
+	password=REDIS_PASSWORD,
+	**REDIS_CONNECTION_SSL_OPTIONS,
+).master_for(REDIS_SENTINEL_MASTER, db=` + sessionDB + `)
+`
+	}
+
+	return `
+# Store the sessions in redis, the cookie only contains the signed session id
+` + client + `
+SESSION_SERVER_SIDE = True
+SESSION_TYPE = 'redis'
+SESSION_USE_SIGNER = True
+SESSION_KEY_PREFIX = 'superset_session:'
+`
+}
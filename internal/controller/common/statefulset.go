@@ -3,7 +3,10 @@ package common
 import (
 	"context"
 	"fmt"
+	"maps"
 	"path"
+	"slices"
+	"strconv"
 	"strings"
 
 	authv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/authentication/v1alpha1"
@@ -23,6 +26,18 @@ import (
 	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
 )
 
+const (
+	// GunicornThreads are the threads of the web server of a node replica,
+	// the default connection pool of the metadata database of the node role is derived from them.
+	GunicornThreads = 20
+
+	// WorkerHeartbeatFile is touched by the celery worker every few seconds, the liveness probe checks its age.
+	// Pinging the worker through the broker does not work with the metadata database as broker.
+	WorkerHeartbeatFile = "/tmp/celery-worker-heartbeat"
+	// WorkerHeartbeatMaxAgeSeconds is the age of the heartbeat file after which the worker is considered dead.
+	WorkerHeartbeatMaxAgeSeconds = 60
+)
+
 var (
 	LogVolumeName    = builder.LogDataVolumeName
 	ConfigVolumeName = "config"
@@ -111,32 +126,12 @@ wait_for_termination()
 }
 
 
-superset db upgrade
-
-set +x # Disable debug mode
-superset fab create-admin \
-	--username "${ADMIN_USERNAME}" \
-	--firstname "${ADMIN_FIRSTNAME}" \
-	--lastname "${ADMIN_LASTNAME}" \
-	--email "${ADMIN_EMAIL}" \
-	--password "${ADMIN_PASSWORD}"
-
-set -x # Enable debug mode
-
-superset init
-
 rm -rf /kubedoop/log/_vector/shutdown
 
 prepare_signal_handlers
 
 
-gunicorn \
-	--bind 0.0.0.0:${SUPERSET_PORT} \
-	--threads 20 \
-	--timeout 300 \
-	--limit-request-line 0 \
-	--limit-request-field_size 0 \
-	'superset.app:create_app()' &
+` + b.getServerCommand() + ` &
 
 
 wait_for_termination $!
@@ -148,6 +143,48 @@ mkdir -p /kubedoop/log/_vector/ && touch /kubedoop/log/_vector/shutdown
 	return util.IndentTab4Spaces(cmds)
 }
 
+// getServerCommand returns the long running process of the role.
+func (b *StatefulSetBuilder) getServerCommand() string {
+	switch b.RoleName {
+	case WorkerRoleName:
+		return `celery \
+	--app=superset.tasks.celery_app:app \
+	worker \
+	--pool=prefork \
+	--concurrency=${CELERY_CONCURRENCY:-4} \
+	-O fair`
+	case BeatRoleName:
+		return `celery \
+	--app=superset.tasks.celery_app:app \
+	beat \
+	--pidfile /tmp/celerybeat.pid \
+	--schedule /tmp/celerybeat-schedule`
+	default:
+		return `gunicorn \
+	--bind ` + b.getAppBindAddress() + `:${SUPERSET_PORT} \
+	--threads ` + strconv.Itoa(GunicornThreads) + ` \
+	--timeout 300 \
+	--limit-request-line 0 \
+	--limit-request-field_size 0 \
+	'superset.app:create_app()'`
+	}
+}
+
+// hasAuthProxy returns whether the oauth2-proxy sidecar runs in front of the web server of the role.
+func (b *StatefulSetBuilder) hasAuthProxy() bool {
+	remoteUser := b.ClusterConfig.RemoteUserAuthentication
+	return b.RoleName == NodeRoleName && remoteUser != nil && remoteUser.Proxy != nil
+}
+
+// getAppBindAddress returns the address gunicorn listens on. Behind the auth proxy it only listens on localhost,
+// superset trusts the user header, so it must not be reachable on the pod IP bypassing the proxy.
+func (b *StatefulSetBuilder) getAppBindAddress() string {
+	if b.hasAuthProxy() {
+		return "127.0.0.1"
+	}
+	return "0.0.0.0"
+}
+
 func (b *StatefulSetBuilder) GetInitContainerCommands() string {
 	cmds := `
 prepare_signal_handlers()
@@ -200,6 +237,18 @@ func (b *StatefulSetBuilder) getAppPort() int32 {
 	return portNum
 }
 
+// getContainerPorts returns the ports of the metrics sidecar, which serves the statsd exporter,
+// or the ports of the main container.
+func (b *StatefulSetBuilder) getContainerPorts(metrics bool) []corev1.ContainerPort {
+	var ports []corev1.ContainerPort
+	for _, port := range b.Ports {
+		if (port.Name == "metrics") == metrics {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
 func (b *StatefulSetBuilder) GetMetricsContainer() builder.ContainerBuilder {
 	containerBuilder := builder.NewContainer(
 		"metrics",
@@ -207,6 +256,7 @@ func (b *StatefulSetBuilder) GetMetricsContainer() builder.ContainerBuilder {
 	)
 	containerBuilder.SetCommand([]string{"sh", "-x", "-c"})
 	containerBuilder.SetArgs([]string{b.GetInitContainerCommands()})
+	containerBuilder.AddPorts(b.getContainerPorts(true))
 	return containerBuilder
 }
 
@@ -218,7 +268,7 @@ func (b *StatefulSetBuilder) GetMainContainer() builder.ContainerBuilder {
 	containerBuilder.SetCommand([]string{"sh", "-x", "-c"})
 	containerBuilder.SetArgs([]string{b.GetMainCommands()})
 	containerBuilder.SetProbeWithHealth()
-	containerBuilder.AddPorts(b.Ports)
+	containerBuilder.AddPorts(b.getContainerPorts(false))
 
 	containerBuilder.AddEnvVars([]corev1.EnvVar{
 		{Name: "SUPERSET_PORT", Value: fmt.Sprintf("%d", b.getAppPort())},
@@ -235,11 +285,65 @@ func (b *StatefulSetBuilder) GetMainContainer() builder.ContainerBuilder {
 	})
 
 	if b.ClusterConfig.CredentialsSecret != "" {
-		InjectCredentials(b.ClusterConfig.CredentialsSecret, containerBuilder)
+		InjectCredentials(b.ClusterConfig, containerBuilder)
+	}
+	if b.ClusterConfig.MetadataDatabase != nil {
+		InjectMetadataDatabase(b.ClusterConfig.MetadataDatabase, containerBuilder)
+	}
+	if b.ClusterConfig.Cache != nil {
+		InjectCache(b.ClusterConfig.Cache, containerBuilder)
+	}
+	if b.ClusterConfig.ResultsBackend != nil {
+		InjectResultsBackend(b.ClusterConfig.ResultsBackend, containerBuilder)
+	}
+	if b.ClusterConfig.AlertsAndReports != nil {
+		InjectAlertsAndReports(b.ClusterConfig.AlertsAndReports, containerBuilder)
+	}
+	InjectSecretKey(b.ClusterName, b.ClusterConfig, containerBuilder)
+
+	if b.ClusterConfig.Authorization != nil && b.ClusterConfig.Authorization.Opa != nil {
+		InjectOpa(b.ClusterConfig.Authorization.Opa, containerBuilder)
+	}
+
+	b.setProbes(containerBuilder)
+
+	return containerBuilder
+}
+
+func (b *StatefulSetBuilder) setProbes(containerBuilder builder.ContainerBuilder) {
+	// celery beat only sends messages to the broker, there is nothing to probe.
+	if b.RoleName == BeatRoleName {
+		return
+	}
+
+	if b.RoleName == WorkerRoleName {
+		// celery workers do not serve http, check the heartbeat file touched by the worker.
+		// Loading the superset app takes a while, so the first check is delayed.
+		probe := &corev1.Probe{
+			ProbeHandler: corev1.ProbeHandler{
+				Exec: &corev1.ExecAction{
+					Command: []string{
+						"sh", "-c",
+						`test $(( $(date +%s) - $(stat -c %Y ` + WorkerHeartbeatFile + `) )) -lt ` +
+							strconv.Itoa(WorkerHeartbeatMaxAgeSeconds),
+					},
+				},
+			},
+			InitialDelaySeconds: 60,
+			PeriodSeconds:       30,
+			TimeoutSeconds:      5,
+			FailureThreshold:    3,
+			SuccessThreshold:    1,
+		}
+		containerBuilder.SetLivenessProbe(probe)
+		return
 	}
 
-	if b.ClusterConfig.Authentication != nil && b.ClusterConfig.Authentication.Oidc != nil {
-		containerBuilder.AddEnvFromSecret(b.ClusterConfig.Authentication.Oidc.ClientCredentialsSecret)
+	// Behind the auth proxy the web server is only reachable on localhost, it is probed through the proxy,
+	// which forwards the health check without authentication.
+	port := intstr.FromInt(int(b.getAppPort()))
+	if b.hasAuthProxy() {
+		port = intstr.FromString(AuthProxyPortName)
 	}
 
 	// add liveness probe
@@ -247,7 +351,7 @@ func (b *StatefulSetBuilder) GetMainContainer() builder.ContainerBuilder {
 		ProbeHandler: corev1.ProbeHandler{
 			HTTPGet: &corev1.HTTPGetAction{
 				Path: "/health",
-				Port: intstr.FromInt(int(b.getAppPort())),
+				Port: port,
 				// TODO: add scheme, if enabled tls, use https, otherwise http
 			},
 		},
@@ -259,8 +363,6 @@ func (b *StatefulSetBuilder) GetMainContainer() builder.ContainerBuilder {
 	}
 	containerBuilder.SetLivenessProbe(probe)
 	containerBuilder.SetReadinessProbe(probe)
-
-	return containerBuilder
 }
 
 func (b *StatefulSetBuilder) GetDefaultAffinityBuilder() *AffinityBuilder {
@@ -277,29 +379,7 @@ func (b *StatefulSetBuilder) GetDefaultAffinityBuilder() *AffinityBuilder {
 	return affinity
 }
 
-// is ldap authentication enabled
-func (b *StatefulSetBuilder) getLdapProvider(ctx context.Context) (*authv1alpha1.LDAPProvider, error) {
-	if b.ClusterConfig.Authentication != nil && b.ClusterConfig.Authentication.AuthenticationClass != "" {
-		authClass := &authv1alpha1.AuthenticationClass{
-			ObjectMeta: metav1.ObjectMeta{
-				Name:      b.ClusterConfig.Authentication.AuthenticationClass,
-				Namespace: b.Client.GetOwnerNamespace(),
-			},
-		}
-		if err := b.Client.GetWithObject(ctx, authClass); err != nil {
-			return nil, err
-		}
-
-		if authClass.Spec.AuthenticationProvider.LDAP == nil {
-			return nil, nil
-		}
-
-		return authClass.Spec.AuthenticationProvider.LDAP, nil
-	}
-	return nil, nil
-}
-
-func (b *StatefulSetBuilder) addAuthLdapCredentials(ldap *authv1alpha1.LDAPProvider) {
+func (b *StatefulSetBuilder) addAuthLdapCredentials(container builder.ContainerBuilder, ldap *authv1alpha1.LDAPProvider) {
 	credentials := ldap.BindCredentials
 
 	scopes := []string{}
@@ -316,13 +396,91 @@ func (b *StatefulSetBuilder) addAuthLdapCredentials(ldap *authv1alpha1.LDAPProvi
 		}
 
 	}
-	b.addSecretVolume("ldap-bind-credentials", credentials.SecretClass, scopes)
+	b.addSecretVolume(container, LDAPBindCredentialsVolumeName, credentials.SecretClass, scopes)
+}
+
+// addAuthLdapTLS mounts the CA of the LDAP server, when its certificate is verified with a secret class.
+func (b *StatefulSetBuilder) addAuthLdapTLS(container builder.ContainerBuilder, ldap *authv1alpha1.LDAPProvider) {
+	caSecretClass := GetLDAPCASecretClass(ldap)
+	if caSecretClass == "" {
+		return
+	}
+	b.addSecretVolume(container, LDAPTLSVolumeName, caSecretClass, nil)
+}
+
+// addAuthOIDC adds the client credentials env vars of every OIDC provider,
+// and mounts the CA of the providers verified with a secret class, once per secret class.
+func (b *StatefulSetBuilder) addAuthOIDC(container builder.ContainerBuilder, methods []AuthenticationMethod) {
+	var caSecretClasses []string
+	for _, method := range methods {
+		clientIDEnv, clientSecretEnv := GetOIDCClientEnvNames(method.Spec.AuthenticationClass)
+		secretName := method.Spec.Oidc.ClientCredentialsSecret
+		container.AddEnvVars([]corev1.EnvVar{
+			{
+				Name: clientIDEnv,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						Key:                  OIDCClientIDSecretKey,
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					},
+				},
+			},
+			{
+				Name: clientSecretEnv,
+				ValueFrom: &corev1.EnvVarSource{
+					SecretKeyRef: &corev1.SecretKeySelector{
+						Key:                  OIDCClientSecretSecretKey,
+						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					},
+				},
+			},
+		})
+
+		caSecretClass := GetOIDCCASecretClass(method.Provider.OIDC)
+		if caSecretClass != "" && !slices.Contains(caSecretClasses, caSecretClass) {
+			caSecretClasses = append(caSecretClasses, caSecretClass)
+		}
+	}
+
+	for i, caSecretClass := range caSecretClasses {
+		name := OIDCTLSVolumeName
+		if i > 0 {
+			name = fmt.Sprintf("%s-%d", OIDCTLSVolumeName, i)
+		}
+		b.addSecretVolume(container, name, caSecretClass, nil)
+	}
 }
 
-func (b *StatefulSetBuilder) addSecretVolume(name string, secretClass string, scopes []string) {
+func (b *StatefulSetBuilder) addSecretVolume(container builder.ContainerBuilder, name string, secretClass string, scopes []string) {
+	b.addSecretVolumeWithAnnotations(container, name, secretClass, scopes, nil)
+}
+
+// addSecretVolumeWithAnnotations adds a secret volume, the annotations are added to the volume claim,
+// e.g. the kerberos service names.
+func (b *StatefulSetBuilder) addSecretVolumeWithAnnotations(
+	container builder.ContainerBuilder,
+	name string,
+	secretClass string,
+	scopes []string,
+	extraAnnotations map[string]string,
+) {
+	secretVolume, secretVolumeMount := NewSecretVolume(name, secretClass, scopes, extraAnnotations)
+	b.AddVolume(secretVolume)
+	container.AddVolumeMount(secretVolumeMount)
+}
+
+// NewSecretVolume returns a volume provisioned by the secret-operator from the secret class,
+// and its mount at the secret directory of the secret class.
+func NewSecretVolume(
+	name string,
+	secretClass string,
+	scopes []string,
+	extraAnnotations map[string]string,
+) (*corev1.Volume, *corev1.VolumeMount) {
 	annotations := map[string]string{
 		constants.AnnotationSecretsClass: secretClass,
 	}
+	maps.Copy(annotations, extraAnnotations)
 	if len(scopes) > 0 {
 		annotations[constants.AnnotationSecretsScope] = strings.Join(scopes, constants.CommonDelimiter)
 	}
@@ -348,15 +506,13 @@ func (b *StatefulSetBuilder) addSecretVolume(name string, secretClass string, sc
 		},
 	}
 
-	b.AddVolume(secretVolume)
-
 	secretVolumeMount := &corev1.VolumeMount{
 		Name:      name,
 		MountPath: path.Join(constants.KubedoopSecretDir, secretClass),
 		ReadOnly:  true,
 	}
 
-	b.GetMainContainer().AddVolumeMount(secretVolumeMount)
+	return secretVolume, secretVolumeMount
 }
 
 func (b *StatefulSetBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
@@ -388,20 +544,57 @@ func (b *StatefulSetBuilder) Build(ctx context.Context) (ctrlclient.Object, erro
 	b.AddContainer(b.GetMetricsContainer().Build())
 	b.SetAffinity(b.GetDefaultAffinityBuilder().Build())
 
-	ldap, err := b.getLdapProvider(ctx)
+	// The secret key is read from env, roll the pods when it is rotated.
+	// The previous key is only read by the re-encrypt job, removing it does not roll the pods.
+	secretKey, _, err := GetSecretKeys(ctx, b.Client, b.ClusterName, b.ClusterConfig)
 	if err != nil {
 		return nil, err
 	}
+	b.AddAnnotations(map[string]string{AnnotationSecretKeyHash: HashSecretKeys(secretKey, "")})
 
-	if ldap != nil {
-		b.addAuthLdapCredentials(ldap)
-		container.AddVolumeMount(&corev1.VolumeMount{
-			Name:      "ldap-bind-credentials",
-			MountPath: path.Join(constants.KubedoopSecretDir, ldap.BindCredentials.SecretClass),
-		})
+	authentication, err := ResolveAuthentication(ctx, b.Client, b.ClusterConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if authentication != nil {
+		if ldap := authentication.GetLDAP(); ldap != nil {
+			if ldap.Provider.LDAP.BindCredentials != nil {
+				b.addAuthLdapCredentials(container, ldap.Provider.LDAP)
+			}
+			b.addAuthLdapTLS(container, ldap.Provider.LDAP)
+		}
+		b.addAuthOIDC(container, authentication.GetOIDC())
+
+		// Only the web server authenticates users, the keytab is scoped to its service.
+		if kerberos := authentication.GetKerberos(); kerberos != nil && b.RoleName == NodeRoleName {
+			b.addKerberos(container, kerberos.Provider.Kerberos.KerberosStorageClass)
+		}
+	}
+
+	if database := b.ClusterConfig.MetadataDatabase; database != nil && database.TLS != nil {
+		b.addMetadataDatabaseTLS(container, database)
+	}
+	if cache := b.ClusterConfig.Cache; cache != nil && cache.TLS != nil && cache.TLS.CASecretClass != "" {
+		b.addRedisTLS(container, cache)
+	}
+	if resultsBackend := b.ClusterConfig.ResultsBackend; resultsBackend != nil && resultsBackend.S3.Credentials.SecretClass != "" {
+		b.addResultsBackendCredentials(container, resultsBackend)
+	}
+
+	// Only the web server is proxied, workers and beat do not serve users.
+	if b.RoleName == NodeRoleName {
+		proxyProvider, err := ResolveAuthProxyProvider(ctx, b.Client, b.ClusterConfig)
+		if err != nil {
+			return nil, err
+		}
+		if proxyProvider != nil {
+			b.addAuthProxy(b.ClusterConfig.RemoteUserAuthentication.Proxy, proxyProvider)
+		}
 	}
 
 	b.AddContainer(container.Build())
+	b.AddInitContainer(NewWaitForDependenciesContainer(b.ClusterConfig, b.GetImage()).Build())
 
 	if b.ClusterConfig != nil && b.ClusterConfig.VectorAggregatorConfigMapName != "" {
 		vectorBuilder := builder.NewVector(
@@ -0,0 +1,150 @@
+package job
+
+import (
+	"context"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	corev1 "k8s.io/api/core/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+	"github.com/zncdatadev/superset-operator/internal/controller/common"
+)
+
+var _ builder.JobBuilder = &JobBuilder{}
+
+// JobBuilder builds a one-shot job running superset cli commands
+// with the same credentials and superset config as the role groups.
+type JobBuilder struct {
+	builder.Job
+
+	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
+	// ConfigMapName is the name of the configmap containing superset_config.py
+	ConfigMapName string
+	Commands      string
+	// PreviousSecretKey adds the previous secret key, only the re-encrypt job needs it.
+	PreviousSecretKey bool
+}
+
+func NewJobBuilder(
+	client *client.Client,
+	name string,
+	roleInfo reconciler.RoleInfo,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+	configMapName string,
+	commands string,
+) *JobBuilder {
+	return &JobBuilder{
+		Job: *builder.NewGenericJobBuilder(
+			client,
+			name,
+			image,
+			nil,
+			nil,
+			func(o *builder.Options) {
+				o.ClusterName = roleInfo.GetClusterName()
+				o.RoleName = roleInfo.GetRoleName()
+				o.Labels = roleInfo.GetLabels()
+				o.Annotations = roleInfo.GetAnnotations()
+			},
+		).(*builder.Job),
+		ClusterConfig: clusterConfig,
+		ConfigMapName: configMapName,
+		Commands:      commands,
+	}
+}
+
+func (b *JobBuilder) getMainContainer() builder.ContainerBuilder {
+	containerBuilder := builder.NewContainer(
+		b.RoleName,
+		b.GetImage(),
+	)
+	containerBuilder.SetCommand([]string{"sh", "-x", "-c"})
+	containerBuilder.SetArgs([]string{util.IndentTab4Spaces(`
+set -e
+
+mkdir --parents /kubedoop/app/pythonpath
+
+cp /kubedoop/mount/config/* /kubedoop/app/pythonpath
+` + b.Commands)})
+
+	containerBuilder.AddVolumeMount(&corev1.VolumeMount{
+		Name:      common.ConfigVolumeName,
+		MountPath: constants.KubedoopConfigDirMount,
+		ReadOnly:  true,
+	})
+	containerBuilder.AddVolumeMount(&corev1.VolumeMount{
+		Name:      common.LogVolumeName,
+		MountPath: constants.KubedoopLogDir,
+	})
+
+	if b.ClusterConfig.CredentialsSecret != "" {
+		common.InjectCredentials(b.ClusterConfig, containerBuilder)
+	}
+	if b.ClusterConfig.MetadataDatabase != nil {
+		common.InjectMetadataDatabase(b.ClusterConfig.MetadataDatabase, containerBuilder)
+	}
+	if b.ClusterConfig.Cache != nil {
+		common.InjectCache(b.ClusterConfig.Cache, containerBuilder)
+	}
+	if b.ClusterConfig.ResultsBackend != nil {
+		common.InjectResultsBackend(b.ClusterConfig.ResultsBackend, containerBuilder)
+	}
+	common.InjectSecretKey(b.ClusterName, b.ClusterConfig, containerBuilder)
+	if b.PreviousSecretKey {
+		common.InjectPreviousSecretKey(b.ClusterName, b.ClusterConfig, containerBuilder)
+	}
+
+	return containerBuilder
+}
+
+func (b *JobBuilder) Build(ctx context.Context) (ctrlclient.Object, error) {
+	b.AddVolumes([]corev1.Volume{
+		{
+			Name: common.LogVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				EmptyDir: &corev1.EmptyDirVolumeSource{},
+			},
+		},
+		{
+			Name: common.ConfigVolumeName,
+			VolumeSource: corev1.VolumeSource{
+				ConfigMap: &corev1.ConfigMapVolumeSource{
+					DefaultMode:          &[]int32{420}[0],
+					LocalObjectReference: corev1.LocalObjectReference{Name: b.ConfigMapName},
+				},
+			},
+		},
+	})
+
+	containerBuilder := b.getMainContainer()
+	if tlsVolume, tlsVolumeMount := common.NewMetadataDatabaseTLSVolume(b.ClusterConfig.MetadataDatabase); tlsVolume != nil {
+		b.AddVolume(tlsVolume)
+		containerBuilder.AddVolumeMount(tlsVolumeMount)
+	}
+	if tlsVolume, tlsVolumeMount := common.NewRedisTLSVolume(b.ClusterConfig.Cache); tlsVolume != nil {
+		b.AddVolume(tlsVolume)
+		containerBuilder.AddVolumeMount(tlsVolumeMount)
+	}
+	if credentialsVolume, credentialsVolumeMount := common.NewResultsBackendCredentialsVolume(b.ClusterConfig.ResultsBackend); credentialsVolume != nil {
+		b.AddVolume(credentialsVolume)
+		containerBuilder.AddVolumeMount(credentialsVolumeMount)
+	}
+
+	b.AddContainer(containerBuilder.Build())
+	b.AddInitContainer(common.NewWaitForDependenciesContainer(b.ClusterConfig, b.GetImage()).Build())
+	b.SetRestPolicy(&[]corev1.RestartPolicy{corev1.RestartPolicyNever}[0])
+
+	obj, err := b.GetObject()
+	if err != nil {
+		return nil, err
+	}
+	obj.Spec.BackoffLimit = &[]int32{3}[0]
+
+	return obj, nil
+}
@@ -0,0 +1,229 @@
+package job
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"time"
+
+	"github.com/zncdatadev/operator-go/pkg/builder"
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+	"github.com/zncdatadev/superset-operator/internal/controller/common"
+)
+
+const (
+	InitRoleName = "init"
+
+	// AnnotationProductVersion records the superset version the job was run for.
+	AnnotationProductVersion = "superset.kubedoop.dev/product-version"
+)
+
+var (
+	logger = ctrl.Log.WithName("reconciler").WithName("job")
+
+	// RequeueAfter is the interval to check the job again while it is running.
+	RequeueAfter = 10 * time.Second
+	// FailedRequeueAfter is the interval to check a failed job again, and the backoff of the first retry
+	// of the init job. Other failed jobs are only replaced when their spec changes or they are deleted by the user.
+	FailedRequeueAfter = time.Minute
+)
+
+var _ reconciler.Reconciler = &InitReconciler{}
+
+// InitReconciler runs the one-shot job which upgrades the metadata database,
+// creates the admin user and initializes the superset roles and permissions.
+//
+// The job name is keyed on the product version and the hash of the superset config,
+// so a new job is only run when one of them changes, or as a new attempt after the job failed.
+// Reconcile does not return until the job succeeded, so it gates the role groups
+// registered after it.
+type InitReconciler struct {
+	Client        *client.Client
+	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
+	RoleInfo      reconciler.RoleInfo
+	Image         *util.Image
+
+	condition *metav1.Condition
+}
+
+func NewInitReconciler(
+	client *client.Client,
+	clusterInfo reconciler.ClusterInfo,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+) *InitReconciler {
+	return &InitReconciler{
+		Client:        client,
+		ClusterConfig: clusterConfig,
+		RoleInfo: reconciler.RoleInfo{
+			ClusterInfo: clusterInfo,
+			RoleName:    InitRoleName,
+		},
+		Image: image,
+	}
+}
+
+func (r *InitReconciler) GetName() string {
+	return r.RoleInfo.GetFullName()
+}
+
+func (r *InitReconciler) GetNamespace() string {
+	return r.Client.GetOwnerNamespace()
+}
+
+func (r *InitReconciler) GetClient() *client.Client {
+	return r.Client
+}
+
+// GetCondition returns the Initialized condition observed by the last Reconcile, nil if not reconciled yet.
+func (r *InitReconciler) GetCondition() *metav1.Condition {
+	return r.condition
+}
+
+func (r *InitReconciler) getCommands() string {
+	return `
+superset db upgrade
+
+set +x # Disable debug mode
+superset fab create-admin \
+	--username "${ADMIN_USERNAME}" \
+	--firstname "${ADMIN_FIRSTNAME}" \
+	--lastname "${ADMIN_LASTNAME}" \
+	--email "${ADMIN_EMAIL}" \
+	--password "${ADMIN_PASSWORD}"
+
+set -x # Enable debug mode
+
+superset init
+`
+}
+
+// reconcileConfigMap reconciles the superset config used by the job, and returns its content.
+// Authentication, authorization and log aggregation are not needed to initialize the database,
+// so they are left out of the config and its hash.
+func (r *InitReconciler) reconcileConfigMap(ctx context.Context) (string, error) {
+	clusterConfig := r.ClusterConfig.DeepCopy()
+	clusterConfig.AuthenticationClasses = nil
+	clusterConfig.Authentication = nil
+	clusterConfig.RemoteUserAuthentication = nil
+	clusterConfig.Authorization = nil
+	clusterConfig.VectorAggregatorConfigMapName = ""
+
+	configBuilder := &common.SupersetConfigMapBuilder{
+		ConfigMapBuilder: *builder.NewConfigMapBuilder(
+			r.Client,
+			r.GetName(),
+			func(o *builder.Options) {
+				o.Labels = r.RoleInfo.GetLabels()
+				o.Annotations = r.RoleInfo.GetAnnotations()
+			},
+		),
+		ClusterConfig: clusterConfig,
+		ClusterName:   r.RoleInfo.GetClusterName(),
+		RoleName:      r.RoleInfo.GetRoleName(),
+	}
+
+	obj, err := configBuilder.Build(ctx)
+	if err != nil {
+		return "", err
+	}
+	if _, err := r.Client.CreateOrUpdate(ctx, obj); err != nil {
+		return "", err
+	}
+
+	return obj.(*corev1.ConfigMap).Data[common.SupersetConfigFilename], nil
+}
+
+func (r *InitReconciler) getJobName(config string) (string, error) {
+	image, err := r.Image.GetImageWithTag()
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256([]byte(image + "\n" + config))
+	return r.GetName() + "-" + hex.EncodeToString(hash[:])[:8], nil
+}
+
+func (r *InitReconciler) setCondition(status metav1.ConditionStatus, reason, message string) {
+	r.condition = &metav1.Condition{
+		Type:    supersetv1alpha1.ConditionTypeInitialized,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func (r *InitReconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	config, err := r.reconcileConfigMap(ctx)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	name, err := r.getJobName(config)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	job, retryAfter, err := runJobWithRetries(ctx, r.Client, r.RoleInfo.GetLabels(), name, func(name string) *JobBuilder {
+		jobBuilder := NewJobBuilder(
+			r.Client,
+			name,
+			r.RoleInfo,
+			r.ClusterConfig,
+			r.Image,
+			r.GetName(),
+			r.getCommands(),
+		)
+		jobBuilder.AddAnnotations(map[string]string{AnnotationProductVersion: r.Image.ProductVersion})
+		return jobBuilder
+	})
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	name = job.Name
+
+	if isJobFinished(job, batchv1.JobFailed) {
+		logger.Info("Init job failed", "namespace", r.GetNamespace(), "name", name, "retryAfter", retryAfter)
+		r.setCondition(
+			metav1.ConditionFalse,
+			supersetv1alpha1.ConditionReasonJobFailed,
+			fmt.Sprintf("Job %s failed, check its pod logs. It is run again in %s", name, retryAfter.Round(time.Second)),
+		)
+		return ctrl.Result{RequeueAfter: retryAfter}, nil
+	}
+
+	if !isJobFinished(job, batchv1.JobComplete) {
+		logger.Info("Init job is running", "namespace", r.GetNamespace(), "name", name)
+		r.setCondition(
+			metav1.ConditionFalse,
+			supersetv1alpha1.ConditionReasonJobRunning,
+			fmt.Sprintf("Job %s is running", name),
+		)
+		return ctrl.Result{RequeueAfter: RequeueAfter}, nil
+	}
+
+	r.setCondition(
+		metav1.ConditionTrue,
+		supersetv1alpha1.ConditionReasonJobSucceeded,
+		fmt.Sprintf("Job %s succeeded for version %s", name, r.Image.ProductVersion),
+	)
+
+	if err := deleteStaleJobs(ctx, r.Client, r.RoleInfo.GetLabels(), name); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// Ready always returns an empty result, Reconcile already waits for the job.
+func (r *InitReconciler) Ready(ctx context.Context) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
@@ -0,0 +1,170 @@
+package job
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zncdatadev/operator-go/pkg/client"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrlclient "sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// AnnotationJobAttempt records the attempt of a job which is run again after it failed, the first one is 0.
+	AnnotationJobAttempt = "superset.kubedoop.dev/job-attempt"
+)
+
+var (
+	// MaxFailedBackoff caps the backoff before a failed job is run again,
+	// it doubles from FailedRequeueAfter with every attempt.
+	MaxFailedBackoff = 15 * time.Minute
+)
+
+// runJob creates the job if it does not exist yet, and returns it with its current status.
+// The job spec is immutable, so an existing job with the same name is never updated.
+func runJob(ctx context.Context, client *client.Client, jobBuilder *JobBuilder) (*batchv1.Job, error) {
+	obj, err := jobBuilder.Build(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := client.CreateDoesNotExist(ctx, obj); err != nil {
+		return nil, err
+	}
+
+	job := &batchv1.Job{}
+	if err := client.GetWithOwnerNamespace(ctx, obj.GetName(), job); err != nil {
+		return nil, err
+	}
+	return job, nil
+}
+
+// runJobWithRetries runs the job like runJob, and replaces it with a new attempt once it failed
+// and the backoff of the attempt passed, e.g. because a dependency was not reachable in time.
+// The first attempt is named after the job, the next ones get the attempt as suffix.
+// It returns the latest attempt, and the time until it is replaced if it failed.
+func runJobWithRetries(
+	ctx context.Context,
+	client *client.Client,
+	labels map[string]string,
+	name string,
+	newJobBuilder func(name string) *JobBuilder,
+) (*batchv1.Job, time.Duration, error) {
+	latest, attempt, err := getLatestJobAttempt(ctx, client, labels, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	if latest == nil {
+		job, err := runJob(ctx, client, newJobBuilder(name))
+		return job, 0, err
+	}
+	if !isJobFinished(latest, batchv1.JobFailed) {
+		return latest, 0, nil
+	}
+
+	backoff := FailedRequeueAfter
+	for i := 0; i < attempt && backoff < MaxFailedBackoff; i++ {
+		backoff *= 2
+	}
+	backoff = min(backoff, MaxFailedBackoff)
+	if failedFor := time.Since(getJobFailedTime(latest)); failedFor < backoff {
+		return latest, backoff - failedFor, nil
+	}
+
+	// Only the latest failed attempt is kept for its logs.
+	logger.Info("Running failed job again", "namespace", latest.Namespace, "name", latest.Name, "attempt", attempt+1)
+	if err := client.Client.Delete(
+		ctx,
+		latest,
+		ctrlclient.PropagationPolicy(metav1.DeletePropagationBackground),
+	); ctrlclient.IgnoreNotFound(err) != nil {
+		return nil, 0, err
+	}
+	jobBuilder := newJobBuilder(name + "-" + strconv.Itoa(attempt+1))
+	jobBuilder.AddAnnotations(map[string]string{AnnotationJobAttempt: strconv.Itoa(attempt + 1)})
+	job, err := runJob(ctx, client, jobBuilder)
+	return job, 0, err
+}
+
+// getLatestJobAttempt returns the attempt of the job with the highest number, nil if it was never run.
+func getLatestJobAttempt(
+	ctx context.Context,
+	client *client.Client,
+	labels map[string]string,
+	name string,
+) (*batchv1.Job, int, error) {
+	jobs := &batchv1.JobList{}
+	if err := client.Client.List(
+		ctx,
+		jobs,
+		ctrlclient.InNamespace(client.GetOwnerNamespace()),
+		ctrlclient.MatchingLabels(labels),
+	); err != nil {
+		return nil, 0, err
+	}
+
+	var latest *batchv1.Job
+	latestAttempt := 0
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Name != name && !strings.HasPrefix(job.Name, name+"-") {
+			continue
+		}
+		attempt, _ := strconv.Atoi(job.Annotations[AnnotationJobAttempt])
+		if latest == nil || attempt > latestAttempt {
+			latest, latestAttempt = job, attempt
+		}
+	}
+	return latest, latestAttempt, nil
+}
+
+func getJobFailedTime(job *batchv1.Job) time.Time {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == batchv1.JobFailed {
+			return condition.LastTransitionTime.Time
+		}
+	}
+	return time.Time{}
+}
+
+// deleteStaleJobs deletes the jobs with the given labels except the current one, once the current job succeeded.
+func deleteStaleJobs(ctx context.Context, client *client.Client, labels map[string]string, current string) error {
+	jobs := &batchv1.JobList{}
+	if err := client.Client.List(
+		ctx,
+		jobs,
+		ctrlclient.InNamespace(client.GetOwnerNamespace()),
+		ctrlclient.MatchingLabels(labels),
+	); err != nil {
+		return err
+	}
+
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Name == current {
+			continue
+		}
+		logger.V(1).Info("Deleting stale job", "namespace", job.Namespace, "name", job.Name)
+		if err := client.Client.Delete(
+			ctx,
+			job,
+			ctrlclient.PropagationPolicy(metav1.DeletePropagationBackground),
+		); ctrlclient.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isJobFinished(job *batchv1.Job, conditionType batchv1.JobConditionType) bool {
+	for _, condition := range job.Status.Conditions {
+		if condition.Type == conditionType && condition.Status == corev1.ConditionTrue {
+			return true
+		}
+	}
+	return false
+}
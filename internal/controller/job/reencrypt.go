@@ -0,0 +1,162 @@
+package job
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zncdatadev/operator-go/pkg/client"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	"github.com/zncdatadev/operator-go/pkg/util"
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+	"github.com/zncdatadev/superset-operator/internal/controller/common"
+)
+
+const (
+	ReEncryptRoleName = "re-encrypt"
+)
+
+var _ reconciler.Reconciler = &ReEncryptReconciler{}
+
+// ReEncryptReconciler runs the one-shot job which re-encrypts the secrets stored in the metadata database,
+// e.g. database passwords, from the previous secret key to the current one.
+//
+// The job only runs when the secret key secret contains a previous key,
+// its name is keyed on the hash of both keys, so it runs once per rotation.
+// Once it succeeded, the previous key is removed from the operator owned secret,
+// so the job is not run again with a stale key and the next rotation is accepted.
+// Reconcile does not return until the job succeeded, so the role groups registered after it
+// are only rolled with the new key once the stored secrets can be decrypted with it.
+type ReEncryptReconciler struct {
+	Client        *client.Client
+	ClusterConfig *supersetv1alpha1.ClusterConfigSpec
+	RoleInfo      reconciler.RoleInfo
+	Image         *util.Image
+
+	condition *metav1.Condition
+}
+
+func NewReEncryptReconciler(
+	client *client.Client,
+	clusterInfo reconciler.ClusterInfo,
+	clusterConfig *supersetv1alpha1.ClusterConfigSpec,
+	image *util.Image,
+) *ReEncryptReconciler {
+	return &ReEncryptReconciler{
+		Client:        client,
+		ClusterConfig: clusterConfig,
+		RoleInfo: reconciler.RoleInfo{
+			ClusterInfo: clusterInfo,
+			RoleName:    ReEncryptRoleName,
+		},
+		Image: image,
+	}
+}
+
+func (r *ReEncryptReconciler) GetName() string {
+	return r.RoleInfo.GetFullName()
+}
+
+func (r *ReEncryptReconciler) GetNamespace() string {
+	return r.Client.GetOwnerNamespace()
+}
+
+func (r *ReEncryptReconciler) GetClient() *client.Client {
+	return r.Client
+}
+
+// GetCondition returns the SecretsReEncrypted condition observed by the last Reconcile,
+// nil if the secret key was never rotated.
+func (r *ReEncryptReconciler) GetCondition() *metav1.Condition {
+	return r.condition
+}
+
+func (r *ReEncryptReconciler) setCondition(status metav1.ConditionStatus, reason, message string) {
+	r.condition = &metav1.Condition{
+		Type:    supersetv1alpha1.ConditionTypeSecretsReEncrypted,
+		Status:  status,
+		Reason:  reason,
+		Message: message,
+	}
+}
+
+func (r *ReEncryptReconciler) Reconcile(ctx context.Context) (ctrl.Result, error) {
+	current, previous, err := common.GetSecretKeys(ctx, r.Client, r.RoleInfo.GetClusterName(), r.ClusterConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if current == "" || previous == "" || current == previous {
+		return ctrl.Result{}, nil
+	}
+
+	name := r.GetName() + "-" + common.HashSecretKeys(current, previous)[:8]
+
+	// The job shares the superset config of the init job, which always runs before it.
+	initRoleInfo := reconciler.RoleInfo{ClusterInfo: r.RoleInfo.ClusterInfo, RoleName: InitRoleName}
+
+	jobBuilder := NewJobBuilder(
+		r.Client,
+		name,
+		r.RoleInfo,
+		r.ClusterConfig,
+		r.Image,
+		initRoleInfo.GetFullName(),
+		"superset re-encrypt-secrets\n",
+	)
+	jobBuilder.PreviousSecretKey = true
+
+	job, err := runJob(ctx, r.Client, jobBuilder)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if isJobFinished(job, batchv1.JobFailed) {
+		logger.Info("Re-encrypt job failed", "namespace", r.GetNamespace(), "name", name)
+		r.setCondition(
+			metav1.ConditionFalse,
+			supersetv1alpha1.ConditionReasonJobFailed,
+			fmt.Sprintf("Job %s failed, check the previous secret key and its pod logs. Delete the job to run it again", name),
+		)
+		return ctrl.Result{RequeueAfter: FailedRequeueAfter}, nil
+	}
+
+	if !isJobFinished(job, batchv1.JobComplete) {
+		logger.Info("Re-encrypt job is running", "namespace", r.GetNamespace(), "name", name)
+		r.setCondition(
+			metav1.ConditionFalse,
+			supersetv1alpha1.ConditionReasonJobRunning,
+			fmt.Sprintf("Job %s is running", name),
+		)
+		return ctrl.Result{RequeueAfter: RequeueAfter}, nil
+	}
+
+	r.setCondition(
+		metav1.ConditionTrue,
+		supersetv1alpha1.ConditionReasonJobSucceeded,
+		fmt.Sprintf("Job %s re-encrypted the stored secrets with the current secret key", name),
+	)
+
+	if err := deleteStaleJobs(ctx, r.Client, r.RoleInfo.GetLabels(), name); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	cleared, err := common.ClearPreviousSecretKey(ctx, r.Client, r.RoleInfo.GetClusterName(), r.ClusterConfig)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// The secret is not watched, requeue to apply a rotation deferred while the job was running.
+	if cleared {
+		return ctrl.Result{RequeueAfter: time.Second}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// Ready always returns an empty result, Reconcile already waits for the job.
+func (r *ReEncryptReconciler) Ready(ctx context.Context) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
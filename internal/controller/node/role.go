@@ -2,7 +2,6 @@ package node
 
 import (
 	"context"
-	"strconv"
 
 	commonsv1alpha1 "github.com/zncdatadev/operator-go/pkg/apis/commons/v1alpha1"
 	"github.com/zncdatadev/operator-go/pkg/builder"
@@ -62,8 +61,15 @@ func (r *Reconciler) RegisterResources(ctx context.Context) error {
 		}
 
 		var roleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec
+		listenerClass := constants.ExternalUnstable
+		if r.ClusterConfig.ListenerClass != "" {
+			listenerClass = constants.ListenerClass(r.ClusterConfig.ListenerClass)
+		}
 		if mergedConfig != nil {
 			roleGroupConfig = mergedConfig.RoleGroupConfigSpec
+			if mergedConfig.ListenerClass != "" {
+				listenerClass = constants.ListenerClass(mergedConfig.ListenerClass)
+			}
 		}
 		reconcilers, err := r.RegisterResourceWithRoleGroup(
 			ctx,
@@ -71,6 +77,7 @@ func (r *Reconciler) RegisterResources(ctx context.Context) error {
 			info,
 			overrides,
 			roleGroupConfig,
+			listenerClass,
 		)
 
 		if err != nil {
@@ -90,6 +97,7 @@ func (r *Reconciler) RegisterResourceWithRoleGroup(
 	info reconciler.RoleGroupInfo,
 	overrides *commonsv1alpha1.OverridesSpec,
 	roleGroupConfig *commonsv1alpha1.RoleGroupConfigSpec,
+	listenerClass constants.ListenerClass,
 ) ([]reconciler.Reconciler, error) {
 
 	configmapReconciler := common.NewConfigReconciler(
@@ -118,7 +126,7 @@ func (r *Reconciler) RegisterResourceWithRoleGroup(
 	if annotations == nil {
 		annotations = make(map[string]string)
 	}
-	prometheusAnnotations := GetPrometheusAnnotations(Ports)
+	prometheusAnnotations := common.GetPrometheusAnnotations(Ports)
 	for k, v := range prometheusAnnotations {
 		annotations[k] = v
 	}
@@ -126,9 +134,9 @@ func (r *Reconciler) RegisterResourceWithRoleGroup(
 	serviceReconciler := reconciler.NewServiceReconciler(
 		r.Client,
 		info.GetFullName(),
-		Ports,
+		r.getServicePorts(),
 		func(o *builder.ServiceBuilderOptions) {
-			o.ListenerClass = constants.ExternalUnstable
+			o.ListenerClass = listenerClass
 			o.ClusterName = info.GetClusterName()
 			o.RoleName = info.GetRoleName()
 			o.RoleGroupName = info.GetGroupName()
@@ -139,24 +147,19 @@ func (r *Reconciler) RegisterResourceWithRoleGroup(
 	return []reconciler.Reconciler{configmapReconciler, stsReconciler, serviceReconciler}, nil
 }
 
-// Common annotations for Prometheus scraping
-func GetPrometheusAnnotations(ports []corev1.ContainerPort) map[string]string {
-	metricsPort := 0
-	for _, port := range ports {
-		if port.Name == "metrics" {
-			metricsPort = int(port.ContainerPort)
-			break
-		}
+// getServicePorts returns the ports of the node service.
+// With the auth proxy sidecar, superset trusts the user header, so only the proxy is exposed.
+func (r *Reconciler) getServicePorts() []corev1.ContainerPort {
+	remoteUser := r.ClusterConfig.RemoteUserAuthentication
+	if remoteUser == nil || remoteUser.Proxy == nil {
+		return Ports
 	}
 
-	if metricsPort > 0 {
-		return map[string]string{
-			"prometheus.io/scrape": "true",
-			"prometheus.io/port":   strconv.Itoa(metricsPort),
-			"prometheus.io/path":   "/metrics",
-			"prometheus.io/scheme": "http",
+	ports := []corev1.ContainerPort{{Name: common.AuthProxyPortName, ContainerPort: common.AuthProxyPort}}
+	for _, port := range Ports {
+		if port.Name != "http" {
+			ports = append(ports, port)
 		}
 	}
-
-	return nil
+	return ports
 }
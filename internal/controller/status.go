@@ -0,0 +1,301 @@
+package controller
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/zncdatadev/operator-go/pkg/constants"
+	"github.com/zncdatadev/operator-go/pkg/reconciler"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apimeta "k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sClient "sigs.k8s.io/controller-runtime/pkg/client"
+
+	supersetv1alpha1 "github.com/zncdatadev/superset-operator/api/v1alpha1"
+	"github.com/zncdatadev/superset-operator/internal/controller/common"
+)
+
+// reconcileState is what the controller observed while reconciling the cluster.
+type reconcileState struct {
+	// reconciled is true when every resource was applied without requeue
+	reconciled bool
+	err        error
+	// conditions reported by the cluster resources, e.g. the init job
+	conditions []metav1.Condition
+}
+
+// getDesiredRoleGroups returns the desired replicas of every role group in the spec, keyed by role and role group.
+func getDesiredRoleGroups(instance *supersetv1alpha1.SupersetCluster) []supersetv1alpha1.RoleGroupStatus {
+	var statuses []supersetv1alpha1.RoleGroupStatus
+	add := func(role string, roleGroups map[string]*int32) {
+		for _, name := range slices.Sorted(maps.Keys(roleGroups)) {
+			replicas := int32(1)
+			if roleGroups[name] != nil {
+				replicas = *roleGroups[name]
+			}
+			statuses = append(statuses, supersetv1alpha1.RoleGroupStatus{
+				Role:      role,
+				RoleGroup: name,
+				Replicas:  replicas,
+			})
+		}
+	}
+
+	spec := instance.Spec
+	if spec.Node != nil {
+		roleGroups := map[string]*int32{}
+		for name, rg := range spec.Node.RoleGroups {
+			roleGroups[name] = rg.Replicas
+		}
+		add(common.NodeRoleName, roleGroups)
+	}
+	if spec.Worker != nil {
+		roleGroups := map[string]*int32{}
+		for name, rg := range spec.Worker.RoleGroups {
+			roleGroups[name] = rg.Replicas
+		}
+		add(common.WorkerRoleName, roleGroups)
+	}
+	if spec.Beat != nil {
+		roleGroups := map[string]*int32{}
+		for name := range spec.Beat.RoleGroups {
+			roleGroups[name] = nil
+		}
+		add(common.BeatRoleName, roleGroups)
+	}
+	return statuses
+}
+
+// getRoleGroupStatuses returns the observed replicas of every role group,
+// and whether any of the statefulsets is still rolling out.
+func (r *SupersetClusterReconciler) getRoleGroupStatuses(
+	ctx context.Context,
+	instance *supersetv1alpha1.SupersetCluster,
+) ([]supersetv1alpha1.RoleGroupStatus, bool, error) {
+	statuses := getDesiredRoleGroups(instance)
+	rollingOut := false
+
+	for i := range statuses {
+		status := &statuses[i]
+		sts := &appsv1.StatefulSet{}
+		// The statefulsets are named after the role group info, like in the role group reconcilers.
+		roleGroupInfo := reconciler.RoleGroupInfo{
+			RoleInfo: reconciler.RoleInfo{
+				ClusterInfo: reconciler.ClusterInfo{ClusterName: instance.Name},
+				RoleName:    status.Role,
+			},
+			RoleGroupName: status.RoleGroup,
+		}
+		key := k8sClient.ObjectKey{
+			Namespace: instance.Namespace,
+			Name:      roleGroupInfo.GetFullName(),
+		}
+		if err := r.Get(ctx, key, sts); err != nil {
+			if k8sClient.IgnoreNotFound(err) != nil {
+				return nil, false, err
+			}
+			rollingOut = true
+			continue
+		}
+
+		if sts.Spec.Replicas != nil {
+			status.Replicas = *sts.Spec.Replicas
+		}
+		status.ReadyReplicas = sts.Status.ReadyReplicas
+
+		if sts.Status.ObservedGeneration < sts.Generation ||
+			sts.Status.UpdatedReplicas < status.Replicas ||
+			(sts.Status.CurrentRevision != "" && sts.Status.CurrentRevision != sts.Status.UpdateRevision) {
+			rollingOut = true
+		}
+	}
+	return statuses, rollingOut, nil
+}
+
+// getWaitingRoleGroups returns the role groups and jobs having pods which wait for the dependencies,
+// i.e. their wait-for-dependencies init container has not completed yet.
+func (r *SupersetClusterReconciler) getWaitingRoleGroups(
+	ctx context.Context,
+	instance *supersetv1alpha1.SupersetCluster,
+) ([]string, error) {
+	pods := &corev1.PodList{}
+	if err := r.List(ctx, pods, k8sClient.InNamespace(instance.Namespace), k8sClient.MatchingLabels{
+		constants.LabelKubernetesInstance: instance.Name,
+	}); err != nil {
+		return nil, err
+	}
+
+	var waiting []string
+	for _, pod := range pods.Items {
+		// The pods of the jobs have no role group.
+		roleGroup := pod.Labels[constants.LabelKubernetesComponent]
+		if name := pod.Labels[constants.LabelKubernetesRoleGroup]; name != "" {
+			roleGroup += "/" + name
+		}
+		if pod.DeletionTimestamp != nil || slices.Contains(waiting, roleGroup) {
+			continue
+		}
+		for _, status := range pod.Status.InitContainerStatuses {
+			if status.Name != common.WaitForDependenciesContainerName {
+				continue
+			}
+			if status.State.Terminated == nil || status.State.Terminated.ExitCode != 0 {
+				waiting = append(waiting, roleGroup)
+			}
+		}
+	}
+	slices.Sort(waiting)
+	return waiting, nil
+}
+
+// updateStatus computes the conditions and role group replicas, and writes the status if it changed.
+func (r *SupersetClusterReconciler) updateStatus(
+	ctx context.Context,
+	instance *supersetv1alpha1.SupersetCluster,
+	state reconcileState,
+) error {
+	status := instance.Status.DeepCopy()
+
+	roleGroups, rollingOut, err := r.getRoleGroupStatuses(ctx, instance)
+	if err != nil {
+		return err
+	}
+	status.RoleGroups = roleGroups
+
+	waiting, err := r.getWaitingRoleGroups(ctx, instance)
+	if err != nil {
+		return err
+	}
+
+	if state.reconciled {
+		status.ObservedGeneration = instance.Generation
+	}
+
+	for _, condition := range state.conditions {
+		apimeta.SetStatusCondition(&status.Conditions, condition)
+	}
+
+	if state.err != nil {
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeReconcileFailed,
+			Status:  metav1.ConditionTrue,
+			Reason:  supersetv1alpha1.ConditionReasonReconcileError,
+			Message: state.err.Error(),
+		})
+	} else {
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeReconcileFailed,
+			Status:  metav1.ConditionFalse,
+			Reason:  supersetv1alpha1.ConditionReasonReconcileSucceeded,
+			Message: "The last reconcile succeeded",
+		})
+	}
+
+	progressing := !state.reconciled || rollingOut || status.ObservedGeneration != instance.Generation
+	if progressing {
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeProgressing,
+			Status:  metav1.ConditionTrue,
+			Reason:  supersetv1alpha1.ConditionReasonRollingOut,
+			Message: fmt.Sprintf("Generation %d is rolling out", instance.Generation),
+		})
+	} else {
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeProgressing,
+			Status:  metav1.ConditionFalse,
+			Reason:  supersetv1alpha1.ConditionReasonRolledOut,
+			Message: fmt.Sprintf("Generation %d is rolled out", instance.Generation),
+		})
+	}
+
+	var unavailable, degraded []string
+	for _, rg := range roleGroups {
+		if rg.ReadyReplicas == 0 {
+			unavailable = append(unavailable, rg.Role+"/"+rg.RoleGroup)
+		}
+		if rg.ReadyReplicas < rg.Replicas {
+			degraded = append(degraded, fmt.Sprintf("%s/%s (%d/%d)", rg.Role, rg.RoleGroup, rg.ReadyReplicas, rg.Replicas))
+		}
+	}
+
+	switch {
+	case instance.Spec.ClusterOperation != nil && instance.Spec.ClusterOperation.Stopped:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  supersetv1alpha1.ConditionReasonStopped,
+			Message: "The cluster is stopped",
+		})
+	case len(unavailable) > 0 && len(waiting) > 0:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  supersetv1alpha1.ConditionReasonWaitingForDependencies,
+			Message: fmt.Sprintf("Waiting for dependencies: %v", waiting),
+		})
+	case len(roleGroups) == 0 || len(unavailable) > 0:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeAvailable,
+			Status:  metav1.ConditionFalse,
+			Reason:  supersetv1alpha1.ConditionReasonNotReady,
+			Message: fmt.Sprintf("Role groups without ready replicas: %v", unavailable),
+		})
+	default:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeAvailable,
+			Status:  metav1.ConditionTrue,
+			Reason:  supersetv1alpha1.ConditionReasonReady,
+			Message: "Every role group has ready replicas",
+		})
+	}
+
+	// While rolling out, missing replicas are expected and not reported as degraded.
+	var failedJob *metav1.Condition
+	for _, conditionType := range []string{
+		supersetv1alpha1.ConditionTypeInitialized,
+		supersetv1alpha1.ConditionTypeSecretsReEncrypted,
+	} {
+		condition := apimeta.FindStatusCondition(status.Conditions, conditionType)
+		if condition != nil && condition.Reason == supersetv1alpha1.ConditionReasonJobFailed {
+			failedJob = condition
+			break
+		}
+	}
+	switch {
+	case failedJob != nil:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeDegraded,
+			Status:  metav1.ConditionTrue,
+			Reason:  supersetv1alpha1.ConditionReasonJobFailed,
+			Message: failedJob.Message,
+		})
+	case !progressing && len(degraded) > 0:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeDegraded,
+			Status:  metav1.ConditionTrue,
+			Reason:  supersetv1alpha1.ConditionReasonNotReady,
+			Message: fmt.Sprintf("Role groups missing ready replicas: %v", degraded),
+		})
+	default:
+		apimeta.SetStatusCondition(&status.Conditions, metav1.Condition{
+			Type:    supersetv1alpha1.ConditionTypeDegraded,
+			Status:  metav1.ConditionFalse,
+			Reason:  supersetv1alpha1.ConditionReasonReady,
+			Message: "No role group is degraded",
+		})
+	}
+
+	for i := range status.Conditions {
+		status.Conditions[i].ObservedGeneration = instance.Generation
+	}
+
+	if equality.Semantic.DeepEqual(status, &instance.Status) {
+		return nil
+	}
+	instance.Status = *status
+	return r.Status().Update(ctx, instance)
+}
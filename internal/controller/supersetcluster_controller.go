@@ -22,6 +22,8 @@ import (
 	"github.com/zncdatadev/operator-go/pkg/client"
 	"github.com/zncdatadev/operator-go/pkg/reconciler"
 
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	ctrl "sigs.k8s.io/controller-runtime"
@@ -47,8 +49,10 @@ var (
 // +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch
 // +kubebuilder:rbac:groups=authentication.kubedoop.dev,resources=authenticationclasses,verbs=get;list;watch
 // +kubebuilder:rbac:groups=apps,resources=statefulsets,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch;create;update;patch;delete
 
 func (r *SupersetClusterReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
@@ -82,29 +86,50 @@ func (r *SupersetClusterReconciler) Reconcile(ctx context.Context, req ctrl.Requ
 	clusterRreconciler := cluster.NewReconciler(resourceClient, clusterInfo, &instance.Spec)
 
 	if err := clusterRreconciler.RegisterResources(ctx); err != nil {
+		if statusErr := r.updateStatus(ctx, instance, reconcileState{err: err}); statusErr != nil {
+			logger.Error(statusErr, "Failed to update status")
+		}
 		return ctrl.Result{}, err
 	}
 
-	if result, err := clusterRreconciler.Reconcile(ctx); err != nil {
+	result, err := r.reconcileCluster(ctx, clusterRreconciler)
+	state := reconcileState{
+		reconciled: err == nil && result.IsZero(),
+		err:        err,
+		conditions: clusterRreconciler.GetConditions(),
+	}
+	if statusErr := r.updateStatus(ctx, instance, state); statusErr != nil {
+		if err != nil {
+			logger.Error(statusErr, "Failed to update status")
+			return result, err
+		}
+		return ctrl.Result{}, statusErr
+	}
+	if err != nil || !result.IsZero() {
 		return result, err
-	} else if !result.IsZero() {
-		return result, nil
 	}
 
-	if result, err := clusterRreconciler.Ready(ctx); err != nil {
+	logger.V(0).Info("Reconcile completed")
+
+	return ctrl.Result{}, nil
+}
+
+// reconcileCluster reconciles the cluster resources, then waits for them to be ready.
+func (r *SupersetClusterReconciler) reconcileCluster(ctx context.Context, clusterReconciler *cluster.Reconciler) (ctrl.Result, error) {
+	if result, err := clusterReconciler.Reconcile(ctx); err != nil {
 		return result, err
 	} else if !result.IsZero() {
 		return result, nil
 	}
 
-	logger.V(0).Info("Reconcile completed")
-
-	return ctrl.Result{}, nil
+	return clusterReconciler.Ready(ctx)
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SupersetClusterReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&supersetv1alpha1.SupersetCluster{}).
+		Owns(&appsv1.StatefulSet{}).
+		Owns(&batchv1.Job{}).
 		Complete(r)
 }
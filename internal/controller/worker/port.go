@@ -0,0 +1,12 @@
+package worker
+
+import corev1 "k8s.io/api/core/v1"
+
+var (
+	Ports = []corev1.ContainerPort{
+		{
+			Name:          "metrics",
+			ContainerPort: 9102, // statsd-exporter metrics port
+		},
+	}
+)
@@ -13,8 +13,8 @@ logging.basicConfig(
 
 session = requests.Session()
 
-# Click on "Sign In with keycloak" in Superset
-login_page = session.get("http://test-superset-node-default:8088/login/keycloak?next=")
+# Click on "Sign In with oidc-keycloak" in Superset, the provider is named after the authentication class
+login_page = session.get("http://test-superset-node-default:8088/login/oidc-keycloak?next=")
 
 assert login_page.ok, "Redirection from Superset to Keycloak failed"
 assert login_page.url.startswith("http://keycloak.$NAMESPACE.svc.cluster.local/realms/kubedoop/protocol/openid-connect/auth?response_type=code&client_id=auth2-proxy"), \
@@ -0,0 +1,21 @@
+import sys
+
+import requests
+
+pod_ip = sys.argv[1]
+header = {'X-Forwarded-Preferred-Username': 'admin'}
+
+# Superset only listens on localhost, the proxy can not be bypassed on the pod IP
+try:
+    response = requests.get(f'http://{pod_ip}:8088/login/', headers=header, timeout=10)
+    sys.exit(f'Superset is reachable on the pod IP bypassing the proxy: {response.status_code}')
+except requests.ConnectionError:
+    print('Superset is not reachable on the pod IP')
+
+# The proxy does not forward the forged header of an unauthenticated request
+session = requests.Session()
+response = session.get('http://test-superset-node-default:4180/login/', headers=header, allow_redirects=False, timeout=10)
+assert response.status_code in (302, 401, 403), f'Forged header was not rejected by the proxy: {response.status_code}'
+me = session.get('http://test-superset-node-default:4180/api/v1/me/', headers=header, allow_redirects=False, timeout=10)
+assert me.status_code != 200, f'Logged in with the forged header: {me.text}'
+print('Forged header was rejected')